@@ -1,11 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 
-	"lisp-interpreter/pkg/logger"
 	"lisp-interpreter/pkg/repl"
+	"lisp-interpreter/pkg/runtime"
 )
 
 const usage = `Basic Lisp interpreter with Mark and Sweep GC by Ondrej Bilek
@@ -16,9 +18,23 @@ Usage:
 The commands are:
 	repl	start lisp repl
 	input	interpret text file
+	disasm	print bytecode of text file
 
 The arguments are:
 	-d	Debug GC print
+	-gc	GC mode: marksweep (default), generational, incremental or copying
+	-gcbudget	Objects scanned per incremental GC slice
+	-stress	Collect on every allocation and report use of freed objects
+	-mode	Evaluation mode: tree (default) or bytecode
+	-O0	Run forms as they were parsed (default), overrides -O1
+	-O1	Fold constants, inline arithmetic builtins and drop dead if branches
+	-maxsteps	Abort evaluation of a form after this many function calls
+	-maxdepth	Abort evaluation of a form nesting more lambda calls
+	-maxobjects	Abort evaluation of a form keeping more objects in the heap
+	-timeout	Abort evaluation of a form running longer, e.g. 2s
+	-profile	Builtins: full (default), noio without file access and printing or pure arithmetic
+	-allow	Comma separated builtins of the profile to install, others are left out
+	-deny	Comma separated builtins of the profile to leave out
 
 `
 
@@ -29,23 +45,138 @@ Usage:
 
 The arguments are:
 	-d	Debug GC print
+	-gc	GC mode: marksweep (default), generational, incremental or copying
+	-gcbudget	Objects scanned per incremental GC slice
+	-stress	Collect on every allocation and report use of freed objects
+	-mode	Evaluation mode: tree (default) or bytecode
+	-O0	Run forms as they were parsed (default), overrides -O1
+	-O1	Fold constants, inline arithmetic builtins and drop dead if branches
+	-maxsteps	Abort evaluation of a form after this many function calls
+	-maxdepth	Abort evaluation of a form nesting more lambda calls
+	-maxobjects	Abort evaluation of a form keeping more objects in the heap
+	-timeout	Abort evaluation of a form running longer, e.g. 2s
+	-profile	Builtins: full (default), noio without file access and printing or pure arithmetic
+	-allow	Comma separated builtins of the profile to install, others are left out
+	-deny	Comma separated builtins of the profile to leave out
 
 `
 
+const usageDisasm = `Print bytecode of text file without running it
+
+Usage:
+	lisp-interpreter disasm [path] [arguments]
+
+The arguments are:
+	-d	Debug GC print
+	-gc	GC mode: marksweep (default), generational, incremental or copying
+	-gcbudget	Objects scanned per incremental GC slice
+	-stress	Collect on every allocation and report use of freed objects
+	-O0	Run forms as they were parsed (default), overrides -O1
+	-O1	Fold constants, inline arithmetic builtins and drop dead if branches
+
+`
+
+func parseArguments(args []string) ([]runtime.Option, bool) {
+	flags := flag.NewFlagSet("lisp-interpreter", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+
+	debug := flags.Bool("d", false, "")
+	gcMode := flags.String("gc", runtime.GCMarkSweep.String(), "")
+	gcBudget := flags.Int("gcbudget", runtime.GCSliceBudget, "")
+	stress := flags.Bool("stress", false, "")
+	evalMode := flags.String("mode", runtime.ModeTree.String(), "")
+	noOptimize := flags.Bool("O0", false, "")
+	optimize := flags.Bool("O1", false, "")
+	maxSteps := flags.Int("maxsteps", 0, "")
+	maxDepth := flags.Int("maxdepth", 0, "")
+	maxObjects := flags.Int("maxobjects", 0, "")
+	timeout := flags.Duration("timeout", 0, "")
+	profileName := flags.String("profile", runtime.ProfileFull.String(), "")
+	allow := flags.String("allow", "", "")
+	deny := flags.String("deny", "", "")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+
+	mode, err := runtime.ParseGCMode(*gcMode)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+
+	eval, err := runtime.ParseEvalMode(*evalMode)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+
+	profile, err := runtime.ParseProfile(*profileName)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+
+	optLevel := runtime.O0
+	if *optimize && !*noOptimize {
+		optLevel = runtime.O1
+	}
+
+	if *debug {
+		fmt.Println("GC logging active")
+	}
+
+	options := []runtime.Option{
+		runtime.WithGCMode(mode),
+		runtime.WithGCSliceBudget(*gcBudget),
+		runtime.WithGCStress(*stress),
+		runtime.WithEvalMode(eval),
+		runtime.WithOptimization(optLevel),
+		runtime.WithDebug(*debug),
+		runtime.WithLimits(runtime.Limits{
+			MaxSteps:   *maxSteps,
+			MaxDepth:   *maxDepth,
+			MaxObjects: *maxObjects,
+			Timeout:    *timeout,
+		}),
+		runtime.WithProfile(profile),
+	}
+	if *allow != "" {
+		names, err := runtime.ParseBuiltins(*allow)
+		if err != nil {
+			fmt.Println(err)
+			return nil, false
+		}
+		options = append(options, runtime.WithAllowedBuiltins(names...))
+	}
+	if *deny != "" {
+		names, err := runtime.ParseBuiltins(*deny)
+		if err != nil {
+			fmt.Println(err)
+			return nil, false
+		}
+		options = append(options, runtime.WithDeniedBuiltins(names...))
+	}
+
+	return options, true
+}
+
 func main() {
 	if len(os.Args) == 1 {
 		fmt.Print(usage)
 		return
 	}
 
-	if os.Args[2] == "-d" || len(os.Args) > 4 && os.Args[3] == "-d" {
-		logger.Active = true
-		fmt.Println("GC logging active")
-	}
-
 	switch os.Args[1] {
 	case "repl":
-		repl.StartWithStdin()
+		options, ok := parseArguments(os.Args[2:])
+		if !ok {
+			fmt.Print(usage)
+			return
+		}
+
+		repl.StartWithStdin(options...)
 		return
 	case "input":
 		if len(os.Args) < 3 {
@@ -53,7 +184,27 @@ func main() {
 			return
 		}
 
-		repl.StartWithFile(os.Args[2])
+		options, ok := parseArguments(os.Args[3:])
+		if !ok {
+			fmt.Print(usageInput)
+			return
+		}
+
+		repl.StartWithFile(os.Args[2], options...)
+		return
+	case "disasm":
+		if len(os.Args) < 3 {
+			fmt.Print(usageDisasm)
+			return
+		}
+
+		options, ok := parseArguments(os.Args[3:])
+		if !ok {
+			fmt.Print(usageDisasm)
+			return
+		}
+
+		repl.DisassembleFile(os.Args[2], options...)
 		return
 	default:
 		fmt.Print(usage)
@@ -0,0 +1,43 @@
+package lisp
+
+import (
+	"reflect"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+var (
+	objectType = reflect.TypeOf((*runtime.Object)(nil)).Elem()
+	valueType  = reflect.TypeOf(Value{})
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// toGo converts object into argument of Go function of type t
+func toGo(o runtime.Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&o).Elem(), nil
+	}
+
+	value, err := valueOf(o)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	v := reflect.New(t).Elem()
+	return v, unmarshal(value, v)
+}
+
+// pushGo converts result of Go function into object and pushes it on the VM
+// stack, so it stays rooted. It has to run inside VM.Try.
+func pushGo(vm *runtime.VM, v reflect.Value) {
+	if v.Type() == objectType && !v.IsNil() {
+		vm.Stack().Push(v.Interface().(runtime.Object))
+		return
+	}
+
+	value, err := marshal(v, make(map[visit]bool))
+	if err != nil {
+		runtime.Error(err)
+	}
+	value.push(vm)
+}
@@ -0,0 +1,139 @@
+// Package lisp embeds the interpreter into Go programs
+package lisp
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"lisp-interpreter/pkg/parser"
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Interpreter evaluates Lisp code in its own VM. It is safe for concurrent
+// use, calls are serialized.
+type Interpreter struct {
+	mu sync.Mutex
+	vm *runtime.VM
+}
+
+func New(options ...runtime.Option) *Interpreter {
+	return &Interpreter{
+		vm: runtime.NewVM(options...),
+	}
+}
+
+// VM returns the underlying VM, it must not be used concurrently with the
+// interpreter
+func (i *Interpreter) VM() *runtime.VM {
+	return i.vm
+}
+
+// EvalString evaluates every form in src and returns value of the last one
+func (i *Interpreter) EvalString(ctx context.Context, src string) (Value, error) {
+	return i.EvalReader(ctx, strings.NewReader(src))
+}
+
+// EvalReader evaluates every form read from r and returns value of the last
+// one. Evaluation stops at the first error or when ctx is done.
+func (i *Interpreter) EvalReader(ctx context.Context, r io.Reader) (Value, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	p := parser.NewParser(i.vm, r)
+	result, err := valueOf(runtime.NewVoidObject().Allocate(i.vm))
+	if err != nil {
+		return Value{}, err
+	}
+
+	for !p.IsEOF() {
+		if err := ctx.Err(); err != nil {
+			return Value{}, err
+		}
+
+		var form runtime.Object
+		if err := i.vm.Try(func() { form = p.Parse() }); err != nil {
+			return Value{}, err
+		}
+
+		// parser returns nil when it reaches the end of input
+		if p.IsEOF() && form.Type() == runtime.TypeNil {
+			break
+		}
+
+		o, err := i.vm.EvalContext(ctx, form)
+		if err != nil {
+			return Value{}, err
+		}
+		if result, err = valueOf(o); err != nil {
+			return Value{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// Define stores value into global variable
+func (i *Interpreter) Define(name string, value Value) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.vm.Try(func() {
+		value.push(i.vm)
+		i.vm.Define(name, i.vm.Stack().Pop())
+	})
+}
+
+// Lookup returns value of global variable
+func (i *Interpreter) Lookup(name string) (Value, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	o, found := i.vm.Lookup(name)
+	if !found {
+		return Value{}, errors.Errorf("variable %s not found", name)
+	}
+	return valueOf(o)
+}
+
+// Call calls function stored in global variable with arguments
+func (i *Interpreter) Call(name string, args ...Value) (Value, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext calls function like Call and aborts the call when ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...Value) (Value, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	function, found := i.vm.Lookup(name)
+	if !found {
+		return Value{}, errors.Errorf("function %s not found", name)
+	}
+
+	var result runtime.Object
+	err := i.vm.Try(func() {
+		for _, arg := range args {
+			arg.push(i.vm)
+		}
+
+		objects := make([]runtime.Object, len(args))
+		for j := len(args) - 1; j >= 0; j-- {
+			objects[j] = i.vm.Stack().Pop()
+		}
+
+		var err error
+		if result, err = i.vm.ApplyContext(ctx, function, objects...); err != nil {
+			runtime.Error(err)
+		}
+	})
+	if err != nil {
+		return Value{}, errors.Wrapf(err, "calling %s", name)
+	}
+
+	value, err := valueOf(result)
+	return value, errors.Wrapf(err, "calling %s", name)
+}
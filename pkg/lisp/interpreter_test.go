@@ -0,0 +1,65 @@
+package lisp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+func TestCyclicHashTable(t *testing.T) {
+	i := New()
+	_, err := i.EvalString(context.Background(), "(define h (make-hash-table)) (hash-table-set! h 1 h) h")
+	if err == nil {
+		t.Error("copying hash table containing itself did not fail")
+	}
+
+	if _, err := i.Lookup("h"); err == nil {
+		t.Error("looking up hash table containing itself did not fail")
+	}
+
+	// shared tables are not cycles
+	v, err := i.EvalString(context.Background(), "(define s (make-hash-table)) (define t (make-hash-table)) (hash-table-set! t 1 s) (hash-table-set! t 2 s) t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := v.Entries(); len(entries) != 2 {
+		t.Errorf("got %d entries, want 2", len(entries))
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	i := New(runtime.WithEvalMode(runtime.ModeBytecode))
+	if _, err := i.EvalString(context.Background(), "(define loop (lambda (n) (loop (+ n 1))))"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := i.CallContext(ctx, "loop", Int(0)); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSandboxOptions(t *testing.T) {
+	i := New(
+		runtime.WithProfile(runtime.ProfilePure),
+		runtime.WithAllowedBuiltins("+", "*", "define"),
+		runtime.WithDeniedBuiltins("*"),
+	)
+
+	if got, want := i.VM().Builtins(), []string{"+", "define"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("builtins %v, want %v", got, want)
+	}
+
+	if _, err := i.EvalString(context.Background(), "(* 2 3)"); err == nil {
+		t.Error("denied builtin is called")
+	}
+	if v, err := i.EvalString(context.Background(), "(+ 2 3)"); err != nil || v.String() != "5" {
+		t.Errorf("allowed builtin returned %v, %v", v, err)
+	}
+}
@@ -0,0 +1,365 @@
+package lisp
+
+import (
+	"math"
+	"reflect"
+	"strings"
+
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Marshal converts Go value into Lisp value. Integers and bools convert to
+// integers and bools, strings to strings, slices and arrays to lists, maps
+// to hash tables and structs to hash tables keyed by field names. Field name
+// can be changed by lisp struct tag, fields tagged "-" are skipped. The
+// interpreter has only integers, so floats have to be integral. Values are
+// used instead of runtime.Object, because objects belong to the heap of a VM
+// and may be collected. Values enter a VM through Interpreter.Define, Call
+// and registered functions. Values referencing themselves through pointers,
+// slices or maps can not be marshalled.
+func Marshal(v interface{}) (Value, error) {
+	return marshal(reflect.ValueOf(v), make(map[visit]bool))
+}
+
+// visit identifies pointer, slice or map on the path to the value being
+// marshalled
+type visit struct {
+	ptr uintptr
+	len int
+}
+
+// enter records that v is being marshalled, it fails when v is reached again
+// through one of its own elements
+func enter(v reflect.Value, visiting map[visit]bool) (visit, error) {
+	key := visit{ptr: v.Pointer()}
+	if v.Kind() != reflect.Ptr {
+		key.len = v.Len()
+	}
+	if visiting[key] {
+		return key, errors.Errorf("can not marshal cyclic %s", v.Type())
+	}
+	visiting[key] = true
+	return key, nil
+}
+
+func marshal(v reflect.Value, visiting map[visit]bool) (Value, error) {
+	if !v.IsValid() {
+		return List(), nil
+	}
+
+	if v.Type() == valueType {
+		return v.Interface().(Value), nil
+	}
+	if v.CanInterface() {
+		if o, ok := v.Interface().(runtime.Object); ok {
+			return valueOf(o)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(int(v.Int())), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return Value{}, errors.Errorf("%d overflows integer", v.Uint())
+		}
+		return Int(int(v.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return Value{}, errors.Errorf("%v is not an integer", f)
+		}
+		return Int(int(f)), nil
+
+	case reflect.String:
+		return String(v.String()), nil
+
+	case reflect.Bool:
+		return Bool(v.Bool()), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			key, err := enter(v, visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			defer delete(visiting, key)
+		}
+
+		items := make([]Value, v.Len())
+		for i := range items {
+			item, err := marshal(v.Index(i), visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			items[i] = item
+		}
+		return List(items...), nil
+
+	case reflect.Map:
+		if v.Len() > 0 {
+			key, err := enter(v, visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			defer delete(visiting, key)
+		}
+
+		entries := make([]Entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := marshal(iter.Key(), visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			value, err := marshal(iter.Value(), visiting)
+			if err != nil {
+				return Value{}, errors.Wrapf(err, "key %s", key)
+			}
+			entries = append(entries, Entry{Key: key, Value: value})
+		}
+		return Table(entries...), nil
+
+	case reflect.Struct:
+		entries := make([]Entry, 0, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value, err := marshal(v.Field(i), visiting)
+			if err != nil {
+				return Value{}, errors.Wrapf(err, "field %s", name)
+			}
+			entries = append(entries, Entry{Key: String(name), Value: value})
+		}
+		return Table(entries...), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return List(), nil
+		}
+		if v.Kind() == reflect.Ptr {
+			key, err := enter(v, visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			defer delete(visiting, key)
+		}
+		return marshal(v.Elem(), visiting)
+	}
+
+	return Value{}, errors.Errorf("can not marshal %s", v.Type())
+}
+
+// fieldName returns key of struct field in hash table
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("lisp")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return field.Name, true
+}
+
+// Unmarshal stores Lisp value into Go value pointed to by target, it is the
+// reverse of Marshal. Keys of hash tables are matched to struct fields by
+// name, case insensitive, keys without field are ignored. Values of type
+// interface{} get integers as int, lists as []interface{} and hash tables as
+// map[interface{}]interface{}. Like Marshal it works with Value, which stays
+// valid after the objects it was read from are collected.
+func Unmarshal(value Value, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("unmarshal target has to be a non nil pointer, not %T", target)
+	}
+	return unmarshal(value, v.Elem())
+}
+
+func unmarshal(value Value, v reflect.Value) error {
+	t := v.Type()
+	if t == valueType {
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.typ != runtime.TypeInteger {
+			break
+		}
+		if v.OverflowInt(int64(value.integer)) {
+			return errors.Errorf("%d overflows %s", value.integer, t)
+		}
+		v.SetInt(int64(value.integer))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.typ != runtime.TypeInteger {
+			break
+		}
+		if value.integer < 0 || v.OverflowUint(uint64(value.integer)) {
+			return errors.Errorf("%d overflows %s", value.integer, t)
+		}
+		v.SetUint(uint64(value.integer))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		if value.typ != runtime.TypeInteger {
+			break
+		}
+		v.SetFloat(float64(value.integer))
+		return nil
+
+	case reflect.String:
+		if value.typ != runtime.TypeString && value.typ != runtime.TypeSymbol {
+			break
+		}
+		v.SetString(value.text)
+		return nil
+
+	case reflect.Bool:
+		if value.typ != runtime.TypeBool {
+			break
+		}
+		v.SetBool(value.boolean)
+		return nil
+
+	case reflect.Slice:
+		if value.typ != runtime.TypeCons && value.typ != runtime.TypeNil {
+			break
+		}
+		v.Set(reflect.MakeSlice(t, len(value.items), len(value.items)))
+		for i, item := range value.items {
+			if err := unmarshal(item, v.Index(i)); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		return nil
+
+	case reflect.Array:
+		if value.typ != runtime.TypeCons && value.typ != runtime.TypeNil {
+			break
+		}
+		if len(value.items) != v.Len() {
+			return errors.Errorf("list of %d items can not be stored in %s", len(value.items), t)
+		}
+		for i, item := range value.items {
+			if err := unmarshal(item, v.Index(i)); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if value.typ != runtime.TypeHashTable {
+			break
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(value.entries)))
+		for _, entry := range value.entries {
+			key := reflect.New(t.Key()).Elem()
+			if err := unmarshal(entry.Key, key); err != nil {
+				return err
+			}
+			// lists and hash tables stored in interface{} keys are not hashable
+			if !key.Comparable() {
+				return errors.Errorf("key %s can not be stored in %s", entry.Key, t)
+			}
+			e := reflect.New(t.Elem()).Elem()
+			if err := unmarshal(entry.Value, e); err != nil {
+				return errors.Wrapf(err, "key %s", entry.Key)
+			}
+			v.SetMapIndex(key, e)
+		}
+		return nil
+
+	case reflect.Struct:
+		if value.typ != runtime.TypeHashTable {
+			break
+		}
+		for _, entry := range value.entries {
+			if entry.Key.typ != runtime.TypeString && entry.Key.typ != runtime.TypeSymbol {
+				continue
+			}
+			field, found := structField(t, entry.Key.text)
+			if !found {
+				continue
+			}
+			if err := unmarshal(entry.Value, v.FieldByIndex(field.Index)); err != nil {
+				return errors.Wrapf(err, "field %s", field.Name)
+			}
+		}
+		return nil
+
+	case reflect.Ptr:
+		if value.typ == runtime.TypeNil {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		e := reflect.New(t.Elem())
+		if err := unmarshal(value, e.Elem()); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			break
+		}
+		if value.typ == runtime.TypeNil || value.typ == runtime.TypeVoid {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		e := reflect.New(naturalType(value)).Elem()
+		if err := unmarshal(value, e); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+
+	return errors.Errorf("can not unmarshal %s into %s", value.typ, t)
+}
+
+// structField finds field stored under key
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := fieldName(t.Field(i)); ok && name == key {
+			return t.Field(i), true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := fieldName(t.Field(i)); ok && strings.EqualFold(name, key) {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// naturalType returns Go type values are stored as when any type is accepted
+func naturalType(value Value) reflect.Type {
+	switch value.typ {
+	case runtime.TypeInteger:
+		return reflect.TypeOf(0)
+	case runtime.TypeString, runtime.TypeSymbol:
+		return reflect.TypeOf("")
+	case runtime.TypeBool:
+		return reflect.TypeOf(false)
+	case runtime.TypeCons:
+		return reflect.TypeOf([]interface{}{})
+	case runtime.TypeHashTable:
+		return reflect.TypeOf(map[interface{}]interface{}{})
+	}
+	return valueType
+}
@@ -0,0 +1,90 @@
+package lisp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type config struct {
+	Name    string `lisp:"name"`
+	Retries int
+	Tags    []string
+	Skipped int `lisp:"-"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := config{Name: "job", Retries: 3, Tags: []string{"a", "b"}, Skipped: 7}
+	v, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out config
+	if err := Unmarshal(v, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skipped = 0
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestUnmarshalUnhashableKey(t *testing.T) {
+	i := New()
+	v, err := i.EvalString(context.Background(), "(define h (make-hash-table)) (hash-table-set! h (1 2) 3) h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var any interface{}
+	if err := Unmarshal(v, &any); err == nil {
+		t.Error("unmarshalling list key into interface{} did not fail")
+	}
+
+	if err := i.Register("keys", func(h interface{}) int { return 0 }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.EvalString(context.Background(), "(keys h)"); err == nil {
+		t.Error("passing list key to interface{} parameter did not fail")
+	}
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestMarshalCycle(t *testing.T) {
+	n := &node{Value: 1}
+	n.Next = n
+	if _, err := Marshal(n); err == nil {
+		t.Error("marshalling struct referencing itself did not fail")
+	}
+
+	s := []interface{}{1}
+	s[0] = s
+	if _, err := Marshal(s); err == nil {
+		t.Error("marshalling slice containing itself did not fail")
+	}
+
+	m := map[string]interface{}{}
+	m["m"] = m
+	if _, err := Marshal(m); err == nil {
+		t.Error("marshalling map containing itself did not fail")
+	}
+
+	// shared values which do not form a cycle are marshalled
+	shared := &node{Value: 2}
+	v, err := Marshal([]*node{shared, shared, {Value: 3, Next: shared}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []node
+	if err := Unmarshal(v, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 || out[2].Next == nil || out[2].Next.Value != 2 {
+		t.Errorf("got %+v", out)
+	}
+}
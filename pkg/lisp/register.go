@@ -0,0 +1,95 @@
+package lisp
+
+import (
+	"reflect"
+
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Register stores Go function into global variable name. Arguments are
+// converted from Lisp objects to parameter types and results back, the
+// number of arguments is checked and variadic functions accept any number
+// of trailing arguments. The function may return nothing, a value, an error
+// or a value and an error. Returned error aborts evaluation like Lisp errors.
+// The function must not call back into the interpreter.
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return errors.Errorf("registering %s: %T is not a function", name, fn)
+	}
+
+	t := f.Type()
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	if t.NumOut() > 2 || (t.NumOut() == 2 && !returnsError) {
+		return errors.Errorf("registering %s: function has to return at most a value and an error", name)
+	}
+
+	builtin := func(numArgs int, vm *runtime.VM) runtime.Object {
+		scope := vm.OpenScope()
+		defer scope.Close()
+
+		args := make([]runtime.Object, numArgs)
+		for j := numArgs - 1; j >= 0; j-- {
+			args[j] = scope.Root(vm.Stack().Pop())
+		}
+
+		in, err := arguments(t, args)
+		if err != nil {
+			runtime.Error(errors.Wrap(err, name))
+		}
+
+		out := f.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				runtime.Error(errors.Wrap(err, name))
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return runtime.NewVoidObject().Allocate(vm)
+		}
+		pushGo(vm, out[0])
+		return vm.Stack().Pop()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.vm.Try(func() {
+		i.vm.Define(name, runtime.NewFunctionObject(name, builtin).Allocate(i.vm))
+	})
+}
+
+// arguments converts objects into arguments of function of type t
+func arguments(t reflect.Type, args []runtime.Object) ([]reflect.Value, error) {
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, errors.Errorf("expects at least %d arguments", fixed)
+		}
+	} else if len(args) != fixed {
+		return nil, errors.Errorf("expects %d arguments", fixed)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for j, arg := range args {
+		var paramType reflect.Type
+		if j < fixed {
+			paramType = t.In(j)
+		} else {
+			paramType = t.In(fixed).Elem()
+		}
+
+		v, err := toGo(arg, paramType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", j+1)
+		}
+		in[j] = v
+	}
+
+	return in, nil
+}
@@ -0,0 +1,107 @@
+package lisp
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// evalInt evaluates src and returns its integer result
+func evalInt(t *testing.T, i *Interpreter, src string) int {
+	t.Helper()
+
+	v, err := i.EvalString(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := v.Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRegisterVariadic(t *testing.T) {
+	i := New()
+	sum := func(base int, rest ...int) int {
+		for _, n := range rest {
+			base += n
+		}
+		return base
+	}
+	if err := i.Register("sum", sum); err != nil {
+		t.Fatal(err)
+	}
+
+	for src, want := range map[string]int{
+		"(sum 1)":       1,
+		"(sum 1 2)":     3,
+		"(sum 1 2 3 4)": 10,
+	} {
+		if got := evalInt(t, i, src); got != want {
+			t.Errorf("%s = %d, want %d", src, got, want)
+		}
+	}
+
+	if _, err := i.EvalString(context.Background(), "(sum)"); err == nil || !strings.Contains(err.Error(), "expects at least 1 arguments") {
+		t.Errorf("calling variadic function without fixed argument returned %v", err)
+	}
+}
+
+func TestRegisterValueAndError(t *testing.T) {
+	i := New()
+	if err := i.Register("atoi", strconv.Atoi); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := evalInt(t, i, `(atoi "42")`); got != 42 {
+		t.Errorf("got %d, want 42", got)
+	}
+
+	_, err := i.EvalString(context.Background(), `(atoi "x")`)
+	if err == nil || !strings.Contains(err.Error(), "atoi") || !strings.Contains(err.Error(), "invalid syntax") {
+		t.Errorf("returned error is not reported, got %v", err)
+	}
+
+	// the interpreter is usable after the error
+	if got := evalInt(t, i, `(+ (atoi "1") 2)`); got != 3 {
+		t.Errorf("got %d, want 3", got)
+	}
+}
+
+func TestRegisterArity(t *testing.T) {
+	i := New()
+	if err := i.Register("add", func(a, b int) int { return a + b }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{"(add 1)", "(add 1 2 3)"} {
+		_, err := i.EvalString(context.Background(), src)
+		if err == nil || !strings.Contains(err.Error(), "expects 2 arguments") {
+			t.Errorf("%s returned %v", src, err)
+		}
+	}
+}
+
+func TestRegisterArgumentType(t *testing.T) {
+	i := New()
+	if err := i.Register("add", func(a, b int) int { return a + b }); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := i.EvalString(context.Background(), `(add 1 "two")`)
+	if err == nil || !strings.Contains(err.Error(), "argument 2") {
+		t.Errorf("string passed to int parameter returned %v", err)
+	}
+}
+
+func TestRegisterRejectsInvalidFunctions(t *testing.T) {
+	i := New()
+	if err := i.Register("n", 42); err == nil {
+		t.Error("registering integer did not fail")
+	}
+	if err := i.Register("pair", func() (int, int) { return 1, 2 }); err == nil {
+		t.Error("registering function returning two values did not fail")
+	}
+}
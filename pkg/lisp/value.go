@@ -0,0 +1,229 @@
+package lisp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Value is a copy of Lisp object made when it leaves the interpreter, so it
+// stays valid after the object is collected
+type Value struct {
+	typ     runtime.ObjectType
+	integer int
+	text    string
+	boolean bool
+	items   []Value
+	entries []Entry
+	printed string
+}
+
+// Entry is a key and value stored in hash table
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+// Int, String, Bool, Symbol, List and Table create values passed to the
+// interpreter
+func Int(i int) Value {
+	return Value{typ: runtime.TypeInteger, integer: i, printed: strconv.Itoa(i)}
+}
+
+func String(s string) Value {
+	return Value{typ: runtime.TypeString, text: s, printed: strconv.Quote(s)}
+}
+
+func Bool(b bool) Value {
+	return Value{typ: runtime.TypeBool, boolean: b, printed: runtime.NewBoolObject(b).String()}
+}
+
+func Symbol(name string) Value {
+	return Value{typ: runtime.TypeSymbol, text: name, printed: name}
+}
+
+func List(items ...Value) Value {
+	if len(items) == 0 {
+		return Value{typ: runtime.TypeNil}
+	}
+
+	printed := make([]string, len(items))
+	for i, item := range items {
+		printed[i] = item.printed
+	}
+	return Value{typ: runtime.TypeCons, items: items, printed: "(" + strings.Join(printed, " ") + ")"}
+}
+
+func Table(entries ...Entry) Value {
+	return Value{typ: runtime.TypeHashTable, entries: entries, printed: "hash-table"}
+}
+
+func (v Value) Type() runtime.ObjectType {
+	return v.typ
+}
+
+func (v Value) IsNil() bool {
+	return v.typ == runtime.TypeNil
+}
+
+func (v Value) IsVoid() bool {
+	return v.typ == runtime.TypeVoid
+}
+
+func (v Value) Int() (int, error) {
+	if v.typ != runtime.TypeInteger {
+		return 0, v.typeError(runtime.TypeInteger)
+	}
+	return v.integer, nil
+}
+
+// Str returns contents of string value
+func (v Value) Str() (string, error) {
+	if v.typ != runtime.TypeString {
+		return "", v.typeError(runtime.TypeString)
+	}
+	return v.text, nil
+}
+
+func (v Value) Bool() (bool, error) {
+	if v.typ != runtime.TypeBool {
+		return false, v.typeError(runtime.TypeBool)
+	}
+	return v.boolean, nil
+}
+
+// Symbol returns name of symbol value
+func (v Value) Symbol() (string, error) {
+	if v.typ != runtime.TypeSymbol {
+		return "", v.typeError(runtime.TypeSymbol)
+	}
+	return v.text, nil
+}
+
+// List returns items of list value, nil is an empty list
+func (v Value) List() ([]Value, error) {
+	if v.typ != runtime.TypeCons && v.typ != runtime.TypeNil {
+		return nil, v.typeError(runtime.TypeCons)
+	}
+	return v.items, nil
+}
+
+// Entries returns entries of hash table value
+func (v Value) Entries() ([]Entry, error) {
+	if v.typ != runtime.TypeHashTable {
+		return nil, v.typeError(runtime.TypeHashTable)
+	}
+	return v.entries, nil
+}
+
+// String returns the value printed the way the REPL prints it
+func (v Value) String() string {
+	return v.printed
+}
+
+func (v Value) typeError(expected runtime.ObjectType) error {
+	return errors.Errorf("value is %s, not %s", v.typ, expected)
+}
+
+// valueOf copies object into value. Hash tables can contain themselves,
+// such cycles can not be copied.
+func valueOf(o runtime.Object) (Value, error) {
+	return copyObject(o, make(map[runtime.Object]bool))
+}
+
+// copyObject copies object, visiting holds hash tables which are being
+// copied
+func copyObject(o runtime.Object, visiting map[runtime.Object]bool) (Value, error) {
+	v := Value{typ: o.Type(), printed: o.String()}
+
+	switch o.Type() {
+	case runtime.TypeInteger:
+		v.integer = o.IntegerValue()
+	case runtime.TypeString, runtime.TypeSymbol:
+		v.text = o.StringValue()
+	case runtime.TypeBool:
+		v.boolean = o.BoolValue()
+	case runtime.TypeCons:
+		for list := o; list.Type() == runtime.TypeCons; list = list.Cdr() {
+			item, err := copyObject(list.Car(), visiting)
+			if err != nil {
+				return Value{}, err
+			}
+			v.items = append(v.items, item)
+		}
+	case runtime.TypeHashTable:
+		if visiting[o] {
+			return Value{}, errors.New("hash-table contains itself")
+		}
+		visiting[o] = true
+		defer delete(visiting, o)
+
+		var err error
+		o.(*runtime.HashTableObject).Each(func(key, value runtime.Object) {
+			if err != nil {
+				return
+			}
+
+			var entry Entry
+			if entry.Key, err = copyObject(key, visiting); err != nil {
+				return
+			}
+			entry.Value, err = copyObject(value, visiting)
+			v.entries = append(v.entries, entry)
+		})
+		if err != nil {
+			return Value{}, err
+		}
+
+		// order of hash table entries is random
+		sort.Slice(v.entries, func(i, j int) bool {
+			return v.entries[i].Key.printed < v.entries[j].Key.printed
+		})
+	}
+
+	return v, nil
+}
+
+// push allocates object for value and pushes it on the VM stack, so it stays
+// rooted. It has to run inside VM.Try.
+func (v Value) push(vm *runtime.VM) {
+	var o runtime.Object
+
+	switch v.typ {
+	case runtime.TypeInteger:
+		o = runtime.NewIntegerObject(v.integer)
+	case runtime.TypeString:
+		o = runtime.NewStringObject(v.text)
+	case runtime.TypeBool:
+		o = runtime.NewBoolObject(v.boolean)
+	case runtime.TypeSymbol:
+		o = runtime.NewSymbolObject(v.text)
+	case runtime.TypeNil:
+		o = runtime.NewNilObject()
+	case runtime.TypeCons:
+		for _, item := range v.items {
+			item.push(vm)
+		}
+		vm.Stack().Push(runtime.NewList(vm, len(v.items)))
+		return
+	case runtime.TypeHashTable:
+		table := runtime.NewHashTableObject(false).Allocate(vm).(*runtime.HashTableObject)
+		vm.Stack().Push(table)
+		for _, entry := range v.entries {
+			entry.Key.push(vm)
+			entry.Value.push(vm)
+			value := vm.Stack().Pop()
+			key := vm.Stack().Pop()
+			table.Set(vm, key, value)
+		}
+		return
+	default:
+		runtime.Error(errors.Errorf("%s value can not be passed to interpreter", v.typ))
+	}
+
+	vm.Stack().Push(o.Allocate(vm))
+}
@@ -1,11 +1,29 @@
 package logger
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
-var Active bool
+// Logger prints GC debug messages of a single VM
+type Logger struct {
+	w      io.Writer
+	active bool
+}
+
+func New(w io.Writer, active bool) *Logger {
+	return &Logger{
+		w:      w,
+		active: active,
+	}
+}
+
+func (l *Logger) Active() bool {
+	return l.active
+}
 
-func Logf(format string, a ...interface{}) {
-	if Active {
-		fmt.Printf("gc: "+format+"\n", a...)
+func (l *Logger) Logf(format string, a ...interface{}) {
+	if l.active {
+		fmt.Fprintf(l.w, "gc: "+format+"\n", a...)
 	}
 }
@@ -20,13 +20,35 @@ type Parser struct {
 	isEOF   bool
 
 	currentRune rune
+
+	// position of the next rune, the previous rune and currentRune
+	position     runtime.Position
+	lastPosition runtime.Position
+	runePosition runtime.Position
+}
+
+func (p *Parser) read() (rune, error) {
+	r, _, err := p.scanner.ReadRune()
+	if err != nil {
+		return r, err
+	}
+
+	p.lastPosition = p.position
+	if r == '\n' {
+		p.position.Line++
+		p.position.Column = 1
+	} else {
+		p.position.Column++
+	}
+
+	return r, nil
 }
 
 func (p *Parser) readRune() (rune, bool) {
 	whitespace := false
 
 	for {
-		r, _, err := p.scanner.ReadRune()
+		r, err := p.read()
 		if err != nil {
 			if err == io.EOF {
 				p.isEOF = true
@@ -39,15 +61,23 @@ func (p *Parser) readRune() (rune, bool) {
 			whitespace = true
 		} else {
 			p.currentRune = r
+			p.runePosition = p.lastPosition
 			return r, whitespace
 		}
 	}
 }
 
 func (p *Parser) unreadRune() {
+	// nothing was read at the end of input
+	if p.isEOF {
+		return
+	}
+
 	if err := p.scanner.UnreadRune(); err != nil {
 		runtime.Error(err)
+		return
 	}
+	p.position = p.lastPosition
 }
 
 func NewParser(vm *runtime.VM, r io.Reader) *Parser {
@@ -55,6 +85,8 @@ func NewParser(vm *runtime.VM, r io.Reader) *Parser {
 		vm:      vm,
 		scanner: bufio.NewReader(r),
 		isEOF:   false,
+
+		position: runtime.Position{Line: 1, Column: 1},
 	}
 }
 
@@ -72,19 +104,31 @@ func (p *Parser) parse() runtime.Object {
 		return runtime.NewNilObject().Allocate(p.vm)
 	}
 
+	position := p.runePosition
+
 	switch ch {
 	case '(':
-		return p.parseList()
+		o := p.parseList()
+		p.vm.SetPosition(o, position)
+		return o
 	case ')':
 		parseError(errors.New("unexpected ')'"))
+	case '"':
+		val := p.parseString()
+		o := runtime.NewStringObject(val).Allocate(p.vm)
+		p.vm.SetPosition(o, position)
+		return o
 	default:
+		var o runtime.Object
 		if unicode.IsDigit(ch) {
 			val := p.parseInteger()
-			return runtime.NewIntegerObject(val).Allocate(p.vm)
+			o = runtime.NewIntegerObject(val).Allocate(p.vm)
 		} else {
 			val := p.parseSymbol()
-			return runtime.NewSymbolObject(val).Allocate(p.vm)
+			o = runtime.NewSymbolObject(val).Allocate(p.vm)
 		}
+		p.vm.SetPosition(o, position)
+		return o
 	}
 
 	return runtime.NewNilObject().Allocate(p.vm)
@@ -95,6 +139,9 @@ func (p *Parser) parseList() runtime.Object {
 	if ch == ')' {
 		return runtime.NewNilObject().Allocate(p.vm)
 	}
+	if ch == EOF {
+		parseError(errors.New("unterminated list"))
+	}
 
 	p.unreadRune()
 
@@ -140,6 +187,39 @@ func (p *Parser) parseSymbol() string {
 	}
 }
 
+func (p *Parser) parseString() string {
+	buffer := make([]rune, 0)
+	escaped := false
+
+	for {
+		r, err := p.read()
+		if err != nil {
+			if err == io.EOF {
+				p.isEOF = true
+				parseError(errors.New("unterminated string"))
+				return string(buffer)
+			}
+			parseError(errors.Wrap(err, "reading rune"))
+			return string(buffer)
+		}
+
+		switch {
+		case escaped:
+			if r == 'n' {
+				r = '\n'
+			}
+			buffer = append(buffer, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return string(buffer)
+		default:
+			buffer = append(buffer, r)
+		}
+	}
+}
+
 func parseError(err error) {
 	runtime.Error(errors.Wrap(err, "parser"))
 }
@@ -9,46 +9,112 @@ import (
 	"lisp-interpreter/pkg/runtime"
 )
 
-func start(r io.Reader) {
-	vm := runtime.NewVM()
+// start reads forms from r and prints results to stdout of VM and errors to
+// its stderr
+func start(vm *runtime.VM, r io.Reader) {
 	p := parser.NewParser(vm, r)
+	out := vm.Stdout()
 
-	fmt.Println("Basic Lisp interpreter with Mark and Sweep GC by Ondrej Bilek")
-	fmt.Println()
+	fmt.Fprintln(out, "Basic Lisp interpreter with Mark and Sweep GC by Ondrej Bilek")
+	fmt.Fprintln(out)
 
 	for {
 		if p.IsEOF() {
 			return
 		}
 
-		fmt.Print("> ")
+		fmt.Fprint(out, "> ")
 
-		object := p.Parse()
+		var object runtime.Object
+		if err := vm.Try(func() { object = p.Parse() }); err != nil {
+			vm.PrintError(err)
+			continue
+		}
 		if object == nil {
-			fmt.Println("nil input")
+			fmt.Fprintln(out, "nil input")
 			return
 		}
 
-		object = object.Evaluate()
+		object, err := vm.EvalForm(object)
+		if err != nil {
+			vm.PrintError(err)
+			continue
+		}
 		if object == nil {
-			fmt.Println("nil input")
+			fmt.Fprintln(out, "nil input")
 			return
 		}
 
-		fmt.Println(object)
+		fmt.Fprintln(out, object)
+	}
+}
+
+func StartWithFile(name string, options ...runtime.Option) {
+	vm := runtime.NewVM(options...)
+
+	file, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintln(vm.Stderr(), err)
+		return
 	}
+	defer file.Close()
+
+	start(vm, file)
 }
 
-func StartWithFile(name string) {
+// DisassembleFile compiles every form of file without running it and prints
+// the bytecode
+func DisassembleFile(name string, options ...runtime.Option) {
+	vm := runtime.NewVM(options...)
+	out := vm.Stdout()
+
 	file, err := os.Open(name)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(vm.Stderr(), err)
 		return
 	}
+	defer file.Close()
+
+	p := parser.NewParser(vm, file)
 
-	start(file)
+	for !p.IsEOF() {
+		var object runtime.Object
+		if err := vm.Try(func() { object = p.Parse() }); err != nil {
+			vm.PrintError(err)
+			continue
+		}
+		if object == nil || p.IsEOF() && object.Type() == runtime.TypeNil {
+			return
+		}
+
+		// immediate values like integers do not have a recorded position
+		if position, found := vm.Position(object); found {
+			fmt.Fprintf(out, "; %s:%s %s\n", name, position, object)
+		} else {
+			fmt.Fprintf(out, "; %s %s\n", name, object)
+		}
+
+		var code *runtime.CodeObject
+		err := vm.Try(func() {
+			object = vm.Optimize(object)
+			vm.Resolve(object)
+			code = vm.Compile(object)
+		})
+		if err != nil {
+			vm.PrintError(err)
+			continue
+		}
+
+		if err := runtime.Disassemble(out, code); err != nil {
+			fmt.Fprintln(vm.Stderr(), err)
+			return
+		}
+		fmt.Fprintln(out)
+	}
 }
 
-func StartWithStdin() {
-	start(os.Stdin)
+// StartWithStdin runs the REPL reading stdin of VM
+func StartWithStdin(options ...runtime.Option) {
+	vm := runtime.NewVM(options...)
+	start(vm, vm.Stdin())
 }
@@ -0,0 +1,60 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+func TestErrorsGoToStderr(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	vm := runtime.NewVM(runtime.WithStdout(&stdout), runtime.WithStderr(&stderr))
+	start(vm, strings.NewReader("(car) (+ 1 2) (cdr)"))
+
+	if want := "error: car operator expects 1 argument\nerror: cdr operator expects 1 argument\n"; stderr.String() != want {
+		t.Errorf("stderr %q, want %q", stderr.String(), want)
+	}
+	if !strings.Contains(stdout.String(), "\n> > 3\n> ") || strings.Contains(stdout.String(), "error") {
+		t.Errorf("stdout %q", stdout.String())
+	}
+}
+
+func TestDisassembleFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "input")
+	if err := ioutil.WriteFile(name, []byte("42\n(sq 3)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	DisassembleFile(name, runtime.WithStdout(&stdout))
+
+	want := `; NAME 42
+code toplevel
+constants:
+	   0  integer  42
+instructions:
+	   0  -        CONST         0      ; 42
+	   1  -        RETURN
+
+; NAME:2:1 (sq 3)
+code toplevel
+globals: sq
+constants:
+	   0  cons     (sq 3)
+	   1  integer  3
+instructions:
+	   0  2:2      GLOBAL        0      ; sq
+	   1  2:1      CHECK_CALL    0 4    ; (sq 3)
+	   2  2:1      CONST         1      ; 3
+	   3  2:1      TAIL_CALL     1
+	   4  -        RETURN
+
+`
+	if want = strings.ReplaceAll(want, "NAME", name); stdout.String() != want {
+		t.Errorf("disasm printed\n%s\nwant\n%s", stdout.String(), want)
+	}
+}
@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -128,7 +130,414 @@ func builtinDefine(numArgs int, vm *VM) Object {
 	expr := vm.Stack().Pop()
 	varName := vm.Stack().Pop().StringValue()
 
-	vm.variables[varName] = expr
+	vm.define(vm.global(varName), expr.Evaluate())
+
+	return NewVoidObject().Allocate(vm)
+}
+
+func builtinGC(numArgs int, vm *VM) Object {
+	vm.Stack().PopTimes(numArgs)
+	vm.GC()
+
+	return NewVoidObject().Allocate(vm)
+}
+
+// builtinGCStats returns GC stats as a property list of names and values,
+// pauses are in microseconds
+func builtinGCStats(numArgs int, vm *VM) Object {
+	vm.Stack().PopTimes(numArgs)
+	stats := vm.GCStats()
+
+	n := 0
+	push := func(name string, value Object) {
+		vm.Stack().Push(NewSymbolObject(name).Allocate(vm))
+		vm.Stack().Push(value.Allocate(vm))
+		n += 2
+	}
+
+	push("mode", NewSymbolObject(stats.Mode.String()))
+	push("collections", NewIntegerObject(stats.Collections))
+	push("freed", NewIntegerObject(stats.Freed))
+	push("live", NewIntegerObject(stats.Live))
+	push("heap-size", NewIntegerObject(stats.HeapSize))
+	push("threshold", NewIntegerObject(vm.collector.gcThreshold()))
+	push("max-pause", NewIntegerObject(int(stats.MaxPause/time.Microsecond)))
+	push("total-pause", NewIntegerObject(int(stats.TotalPause/time.Microsecond)))
+	for t := ObjectType(TypeNil); int(t) <= len(typeNames); t++ {
+		push(t.String(), NewIntegerObject(stats.Types[t]))
+	}
+
+	return NewList(vm, n)
+}
+
+// NewList allocates list of n objects pushed on the stack
+func NewList(vm *VM, n int) Object {
+	vm.Stack().Push(NewNilObject().Allocate(vm))
+	for i := 0; i < n; i++ {
+		vm.Stack().Push(NewConsObject(vm.Stack()).Allocate(vm))
+	}
+
+	return vm.Stack().Pop()
+}
+
+func builtinHeapDump(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("heap-dump operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	name := vm.Stack().Pop().StringValue()
+	if err := vm.DumpHeapFile(name); err != nil {
+		Error(err)
+	}
+
+	return NewVoidObject().Allocate(vm)
+}
+
+func builtinDisassemble(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("disassemble operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	closure, ok := vm.Stack().Pop().(*ClosureObject)
+	if !ok {
+		Error(errors.New("disassemble operator expects compiled function, use -mode bytecode"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	if err := Disassemble(vm.stdout, closure.code); err != nil {
+		Error(err)
+	}
+
+	return NewVoidObject().Allocate(vm)
+}
+
+func builtinMakeWeakBox(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("make-weak-box operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	// the box does not keep value alive until it is registered as weak box
+	scope := vm.OpenScope()
+	defer scope.Close()
+	value := scope.Root(vm.Stack().Pop())
+
+	return NewWeakBoxObject(value).Allocate(vm)
+}
+
+func builtinWeakBoxValue(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("weak-box-value operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	box, ok := vm.Stack().Pop().(*WeakBoxObject)
+	if !ok {
+		Error(errors.New("weak-box-value operator expects weak box"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	if box.Value() == nil {
+		return NewBoolObject(false).Allocate(vm)
+	}
+	return box.Value()
+}
+
+func builtinMakeHashTable(numArgs int, vm *VM) Object {
+	vm.Stack().PopTimes(numArgs)
+	return NewHashTableObject(false).Allocate(vm)
+}
+
+func builtinMakeWeakHashTable(numArgs int, vm *VM) Object {
+	vm.Stack().PopTimes(numArgs)
+	return NewHashTableObject(true).Allocate(vm)
+}
+
+func builtinHashTableSet(numArgs int, vm *VM) Object {
+	if numArgs != 3 {
+		Error(errors.New("hash-table-set! operator expects 3 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	value := vm.Stack().Pop()
+	key := vm.Stack().Pop()
+	table, ok := vm.Stack().Pop().(*HashTableObject)
+	if !ok {
+		Error(errors.New("hash-table-set! operator expects hash table"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	table.Set(vm, key, value)
+
+	return NewVoidObject().Allocate(vm)
+}
+
+// builtinHashTableRef returns value of the key, the default value or F
+func builtinHashTableRef(numArgs int, vm *VM) Object {
+	if numArgs != 2 && numArgs != 3 {
+		Error(errors.New("hash-table-ref operator expects 2 or 3 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	var def Object
+	if numArgs == 3 {
+		def = vm.Stack().Pop()
+	}
+	key := vm.Stack().Pop()
+	table, ok := vm.Stack().Pop().(*HashTableObject)
+	if !ok {
+		Error(errors.New("hash-table-ref operator expects hash table"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	if value, found := table.Get(key); found {
+		return value
+	}
+	if def != nil {
+		return def
+	}
+	return NewBoolObject(false).Allocate(vm)
+}
+
+func builtinHashTableRemove(numArgs int, vm *VM) Object {
+	if numArgs != 2 {
+		Error(errors.New("hash-table-remove! operator expects 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	key := vm.Stack().Pop()
+	table, ok := vm.Stack().Pop().(*HashTableObject)
+	if !ok {
+		Error(errors.New("hash-table-remove! operator expects hash table"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	table.Remove(key)
+
+	return NewVoidObject().Allocate(vm)
+}
+
+func builtinHashTableCount(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("hash-table-count operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	table, ok := vm.Stack().Pop().(*HashTableObject)
+	if !ok {
+		Error(errors.New("hash-table-count operator expects hash table"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	return NewIntegerObject(table.Len()).Allocate(vm)
+}
+
+// builtinRegisterFinalizer registers function called with the object after
+// it becomes unreachable
+func builtinRegisterFinalizer(numArgs int, vm *VM) Object {
+	if numArgs != 2 {
+		Error(errors.New("register-finalizer operator expects 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	function := vm.Stack().Pop()
+	o := vm.Stack().Pop()
+	if !isFunction(function) {
+		Error(errors.New("register-finalizer operator expects function"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	vm.setLispFinalizer(o, function)
+
+	return NewVoidObject().Allocate(vm)
+}
+
+func builtinLambda(numArgs int, vm *VM) Object {
+	args := make([]Object, numArgs)
+	for i := numArgs - 1; i >= 0; i-- {
+		args[i] = vm.Stack().Pop()
+	}
+
+	params, ok := lambdaParams(args)
+	if !ok {
+		return NewVoidObject().Allocate(vm)
+	}
+
+	return NewLambdaObject(params, args[1:], vm.env).Allocate(vm)
+}
+
+// builtinMakeChannel creates channel with buffer of the given capacity,
+// channel without capacity passes a value only to a waiting receiver
+func builtinMakeChannel(numArgs int, vm *VM) Object {
+	if numArgs > 1 {
+		Error(errors.New("make-channel operator expects at most 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	capacity := 0
+	if numArgs == 1 {
+		capacity = vm.Stack().Pop().IntegerValue()
+		if capacity < 0 {
+			Error(errors.New("make-channel operator expects non negative capacity"))
+			return NewVoidObject().Allocate(vm)
+		}
+	}
+
+	return NewChannelObject(capacity).Allocate(vm)
+}
+
+func builtinSend(numArgs int, vm *VM) Object {
+	if numArgs != 2 {
+		Error(errors.New("send operator expects 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	value := vm.Stack().Pop()
+	channel, ok := vm.Stack().Pop().(*ChannelObject)
+	if !ok {
+		Error(errors.New("send operator expects channel"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	vm.send(channel, value)
 
 	return NewVoidObject().Allocate(vm)
 }
+
+// builtinReceive returns the next value of channel or nil once the channel
+// is closed and empty
+func builtinReceive(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("receive operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	channel, ok := vm.Stack().Pop().(*ChannelObject)
+	if !ok {
+		Error(errors.New("receive operator expects channel"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	_, value := vm.receive([]*ChannelObject{channel}, true)
+	return value
+}
+
+func builtinClose(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("close operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	channel, ok := vm.Stack().Pop().(*ChannelObject)
+	if !ok {
+		Error(errors.New("close operator expects channel"))
+		return NewVoidObject().Allocate(vm)
+	}
+	if channel.closed {
+		Error(errors.New("close of closed channel"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	channel.closed = true
+	vm.notify()
+
+	return NewVoidObject().Allocate(vm)
+}
+
+// builtinSelect takes pairs of channel and function and calls the function
+// of the first channel ready to receive with the received value. Function
+// after the last pair is called without arguments when no channel is ready,
+// otherwise select waits.
+func builtinSelect(numArgs int, vm *VM) Object {
+	scope := vm.OpenScope()
+	defer scope.Close()
+
+	// channels and handlers are rooted before anything can allocate
+	args := make([]Object, numArgs)
+	for i := numArgs - 1; i >= 0; i-- {
+		args[i] = scope.Root(vm.Stack().Pop())
+	}
+
+	channels := make([]*ChannelObject, 0)
+	handlers := make([]Object, 0)
+	var def Object
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			def = args[i]
+			break
+		}
+
+		channel, ok := args[i].(*ChannelObject)
+		if !ok {
+			Error(errors.New("select operator expects channel and function pairs"))
+			return NewVoidObject().Allocate(vm)
+		}
+		channels = append(channels, channel)
+		handlers = append(handlers, args[i+1])
+	}
+
+	for _, f := range handlers {
+		if !isFunction(f) {
+			Error(errors.New("select operator expects function"))
+			return NewVoidObject().Allocate(vm)
+		}
+	}
+	if def != nil && !isFunction(def) {
+		Error(errors.New("select operator expects function"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	i, value := vm.receive(channels, def == nil)
+	if i < 0 {
+		return def.EvaluateFunction(0)
+	}
+
+	vm.Stack().Push(value)
+	return handlers[i].EvaluateFunction(1)
+}
+
+// builtinSpawn calls function with arguments in a new task and returns
+// channel which receives its result
+func builtinSpawn(numArgs int, vm *VM) Object {
+	if numArgs < 1 {
+		Error(errors.New("spawn operator expects at least 1 argument"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	args := make([]Object, numArgs-1)
+	for i := numArgs - 2; i >= 0; i-- {
+		args[i] = vm.Stack().Pop()
+	}
+	function := vm.Stack().Pop()
+	if !isFunction(function) {
+		Error(errors.New("spawn operator expects function"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	scope := vm.OpenScope()
+	defer scope.Close()
+	scope.Root(function)
+	for _, arg := range args {
+		scope.Root(arg)
+	}
+
+	result := NewChannelObject(1).Allocate(vm).(*ChannelObject)
+	vm.spawn(function, args, result)
+
+	return result
+}
@@ -0,0 +1,239 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+type Opcode uint8
+
+const (
+	// OpConst pushes constant A
+	OpConst Opcode = iota
+	// OpVoid pushes void
+	OpVoid
+	// OpGlobal pushes value of global variable in cell A
+	OpGlobal
+	// OpDefine pops value into global variable in cell A and pushes void
+	OpDefine
+	// OpLocal pushes argument A of the current function
+	OpLocal
+	// OpCaptured pushes captured variable A of the current closure
+	OpCaptured
+	// OpPop drops the top of the stack
+	OpPop
+	// OpJump jumps to A
+	OpJump
+	// OpJumpIfFalse pops condition and jumps to A if it is false
+	OpJumpIfFalse
+	// OpCheckCall checks callee on top of the stack. If it is not a function,
+	// it is replaced by result of evaluating form in constant A like the tree
+	// walker does and execution jumps to B.
+	OpCheckCall
+	// OpCall calls callee below A arguments and replaces them by result
+	OpCall
+	// OpTailCall is OpCall which reuses frame of the current function
+	OpTailCall
+	// OpClosure pops B captured values and pushes closure of code in constant A
+	OpClosure
+	// OpReturn returns the top of the stack from the current function
+	OpReturn
+)
+
+var opcodeNames = map[Opcode]string{
+	OpConst:       "CONST",
+	OpVoid:        "VOID",
+	OpGlobal:      "GLOBAL",
+	OpDefine:      "DEFINE",
+	OpLocal:       "LOCAL",
+	OpCaptured:    "CAPTURED",
+	OpPop:         "POP",
+	OpJump:        "JUMP",
+	OpJumpIfFalse: "JUMP_IF_FALSE",
+	OpCheckCall:   "CHECK_CALL",
+	OpCall:        "CALL",
+	OpTailCall:    "TAIL_CALL",
+	OpClosure:     "CLOSURE",
+	OpReturn:      "RETURN",
+}
+
+func (o Opcode) String() string {
+	return opcodeNames[o]
+}
+
+type Instruction struct {
+	Op Opcode
+	A  int32
+	B  int32
+}
+
+// frame is an activation of compiled function. Arguments are stored on the
+// VM stack starting at base and the callee is stored just below them.
+type frame struct {
+	closure *ClosureObject
+	pc      int
+	base    int
+}
+
+// run calls closure with numArgs arguments on the stack. Calls between
+// compiled functions do not recurse in Go, they only push frames.
+func (v *VM) run(closure *ClosureObject, numArgs int) Object {
+	s := v.stack
+
+	// move callee below arguments, so the entry frame has the usual layout
+	s.Push(closure)
+	base := len(s.stack) - numArgs - 1
+	copy(s.stack[base+1:], s.stack[base:base+numArgs])
+	s.stack[base] = closure
+
+	if !v.checkArity(closure, numArgs) {
+		return NewVoidObject().Allocate(v)
+	}
+
+	v.enter()
+	frames := []frame{{closure: closure, pc: 0, base: base + 1}}
+
+	for {
+		f := &frames[len(frames)-1]
+		code := f.closure.code
+		ins := code.instructions[f.pc]
+		f.pc++
+
+		switch ins.Op {
+		case OpConst:
+			s.Push(code.constants[ins.A])
+
+		case OpVoid:
+			s.Push(NewVoidObject().Allocate(v))
+
+		case OpGlobal:
+			g := code.globals[ins.A]
+			o := g.value
+			if o == nil {
+				Error(errors.Errorf("variable %s not found", g.name))
+				o = NewVoidObject().Allocate(v)
+			}
+			s.Push(o)
+
+		case OpDefine:
+			v.define(code.globals[ins.A], s.Pop())
+			s.Push(NewVoidObject().Allocate(v))
+
+		case OpLocal:
+			s.Push(s.stack[f.base+int(ins.A)])
+
+		case OpCaptured:
+			s.Push(f.closure.captured[ins.A])
+
+		case OpPop:
+			s.Pop()
+
+		case OpJump:
+			f.pc = int(ins.A)
+
+		case OpJumpIfFalse:
+			if !s.Pop().BoolValue() {
+				f.pc = int(ins.A)
+			}
+
+		case OpCheckCall:
+			callee := s.stack[len(s.stack)-1]
+			if isFunction(callee) {
+				continue
+			}
+
+			s.Pop()
+			form := code.constants[ins.A]
+			if callee.Type() == TypeSyntax {
+				s.Push(callSyntax(v, callee, form))
+			} else {
+				s.Push(form)
+			}
+			f.pc = int(ins.B)
+
+		case OpCall, OpTailCall:
+			v.step()
+			numArgs := int(ins.A)
+			calleeIndex := len(s.stack) - numArgs - 1
+			callee := s.stack[calleeIndex]
+
+			c, ok := callee.(*ClosureObject)
+			if !ok {
+				s.Push(v.callObject(callee, numArgs))
+				continue
+			}
+
+			if !v.checkArity(c, numArgs) {
+				continue
+			}
+
+			if ins.Op == OpTailCall {
+				copy(s.stack[f.base-1:], s.stack[calleeIndex:])
+				s.stack = s.stack[:f.base+numArgs]
+				f.closure = c
+				f.pc = 0
+				continue
+			}
+
+			v.enter()
+			frames = append(frames, frame{closure: c, pc: 0, base: calleeIndex + 1})
+
+		case OpClosure:
+			numCaptured := int(ins.B)
+			captured := make([]Object, numCaptured)
+			copy(captured, s.stack[len(s.stack)-numCaptured:])
+
+			closure := NewClosureObject(code.constants[ins.A].(*CodeObject), captured).Allocate(v)
+			s.PopTimes(numCaptured)
+			s.Push(closure)
+
+		case OpReturn:
+			result := s.Pop()
+			s.stack = s.stack[:f.base-1]
+
+			frames = frames[:len(frames)-1]
+			v.leave()
+			if len(frames) == 0 {
+				return result
+			}
+			s.Push(result)
+		}
+	}
+}
+
+// checkArity reports wrong number of arguments and replaces callee and its
+// arguments on the stack by void
+func (v *VM) checkArity(c *ClosureObject, numArgs int) bool {
+	if numArgs == len(c.code.params) {
+		return true
+	}
+
+	Error(errors.Errorf("%s expects %d arguments", c.code.name, len(c.code.params)))
+	v.stack.PopTimes(numArgs + 1)
+	v.stack.Push(NewVoidObject().Allocate(v))
+	return false
+}
+
+// callObject calls builtin or tree walking function below numArgs arguments
+// on the stack and removes it from the stack
+func (v *VM) callObject(callee Object, numArgs int) Object {
+	if !isFunction(callee) && callee.Type() != TypeSyntax {
+		Error(errors.Errorf("%s is not a function", callee.Type()))
+		v.stack.PopTimes(numArgs + 1)
+		return NewVoidObject().Allocate(v)
+	}
+
+	result := callee.EvaluateFunction(numArgs)
+	v.stack.Pop()
+	return result
+}
+
+// callSyntax calls syntax with unevaluated arguments of the form
+func callSyntax(v *VM, syntax Object, form Object) Object {
+	numArgs := 0
+	for args := form.Cdr(); args.Type() == TypeCons; args = args.Cdr() {
+		v.stack.Push(args.Car())
+		numArgs++
+	}
+
+	return syntax.EvaluateFunction(numArgs)
+}
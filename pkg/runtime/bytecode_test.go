@@ -0,0 +1,63 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// programs are run by TestEquivalence next to the examples
+var programs = map[string]string{
+	"alias":        "(define my-if if)\n(define f (lambda (x) (my-if x 1 2)))\n(f (= 1 1))\n",
+	"alias-define": "(define my-define define)\n(my-define x 3)\n(define f (lambda (y) (my-define z y)))\n(f 4)\nx\nz\n",
+	"alias-lambda": "(define my-lambda lambda)\n(define twice (my-lambda (x) (+ x x)))\n(twice 4)\n((my-lambda (y) ((my-lambda (z) (* y z)) 5)) 6)\n",
+	"alias-if":     "(define my-if if)\n(my-if (= 1 2) 1 2)\n(define f (lambda (x) (my-if (= x 0) 0 (f (- x 1)))))\n(f 10)\n",
+}
+
+// every collector, both evaluation modes and optimization print the same
+// output
+func TestEquivalence(t *testing.T) {
+	sources := map[string]string{}
+	for _, name := range []string{"testInput", "testGc"} {
+		sources[name] = readExample(t, name)
+	}
+	for name, src := range programs {
+		sources[name] = src
+	}
+
+	for name, src := range sources {
+		want := run(t, src, runtime.WithGCMode(runtime.GCMarkSweep), runtime.WithEvalMode(runtime.ModeTree))
+
+		for _, mode := range gcModes {
+			for _, eval := range []runtime.EvalMode{runtime.ModeTree, runtime.ModeBytecode} {
+				for _, level := range []runtime.OptLevel{runtime.O0, runtime.O1} {
+					out := run(t, src, runtime.WithGCMode(mode), runtime.WithEvalMode(eval), runtime.WithOptimization(level))
+					if out != want {
+						t.Errorf("%s %s %s %v: output differs from marksweep tree\n%s", name, mode, eval, level, out)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	src := "(define sq (lambda (x) (* x x)))\n(disassemble sq)\n"
+	want := `
+code lambda (x)
+globals: *
+constants:
+	   0  cons     (* x x)
+instructions:
+	   0  1:25     GLOBAL        0      ; *
+	   1  1:24     CHECK_CALL    0 5    ; (* x x)
+	   2  1:27     LOCAL         0      ; x
+	   3  1:29     LOCAL         0      ; x
+	   4  1:24     TAIL_CALL     2
+	   5  1:12     RETURN
+
+`
+	if out := run(t, src, runtime.WithEvalMode(runtime.ModeBytecode)); out != want {
+		t.Errorf("disassemble printed\n%s\nwant\n%s", out, want)
+	}
+}
@@ -0,0 +1,324 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+// compiler translates parsed forms of one function into bytecode
+type compiler struct {
+	vm     *VM
+	parent *compiler
+	scope  *HandleScope
+
+	name     string
+	params   []string
+	captured []string
+
+	instructions []Instruction
+	positions    []Position
+	constants    []Object
+	globals      []*Global
+
+	// position of the form being compiled
+	position Position
+}
+
+// Compile translates parsed form into code of a function without parameters
+func (v *VM) Compile(form Object) *CodeObject {
+	scope := v.OpenScope()
+	defer scope.Close()
+	scope.Root(form)
+
+	c := &compiler{
+		vm:    v,
+		scope: scope,
+		name:  "toplevel",
+	}
+	c.compile(form, true)
+	c.emit(OpReturn, 0, 0)
+
+	return c.code()
+}
+
+func (c *compiler) code() *CodeObject {
+	code := NewCodeObject(c.name, c.params, c.instructions, c.positions, c.constants, c.globals, c.captured)
+	return code.Allocate(c.vm).(*CodeObject)
+}
+
+func (c *compiler) emit(op Opcode, a, b int) int {
+	c.instructions = append(c.instructions, Instruction{Op: op, A: int32(a), B: int32(b)})
+	c.positions = append(c.positions, c.position)
+	return len(c.instructions) - 1
+}
+
+// patch sets jump target of instruction to the next instruction
+func (c *compiler) patch(index int) {
+	if c.instructions[index].Op == OpCheckCall {
+		c.instructions[index].B = int32(len(c.instructions))
+	} else {
+		c.instructions[index].A = int32(len(c.instructions))
+	}
+}
+
+func (c *compiler) constant(o Object) int {
+	for i, constant := range c.constants {
+		if constant == o {
+			return i
+		}
+	}
+
+	c.constants = append(c.constants, c.scope.Root(o))
+	return len(c.constants) - 1
+}
+
+// global returns index of cell of global variable in code
+func (c *compiler) global(name string) int {
+	g := c.vm.global(name)
+	for i, global := range c.globals {
+		if global == g {
+			return i
+		}
+	}
+
+	c.globals = append(c.globals, g)
+	return len(c.globals) - 1
+}
+
+func (c *compiler) compile(form Object, tail bool) {
+	if position, found := c.vm.positions[form]; found {
+		saved := c.position
+		c.position = position
+		defer func() {
+			c.position = saved
+		}()
+	}
+
+	switch form.Type() {
+	case TypeSymbol:
+		c.compileSymbol(form)
+	case TypeCons:
+		c.compileList(form, tail)
+	default:
+		c.emit(OpConst, c.constant(form), 0)
+	}
+}
+
+func (c *compiler) compileSymbol(symbol Object) {
+	if op, index, found := c.resolve(symbol.StringValue()); found {
+		c.emit(op, index, 0)
+		return
+	}
+
+	c.emit(OpGlobal, c.global(symbol.StringValue()), 0)
+}
+
+// resolve finds variable in parameters of the function or captures it from
+// enclosing functions
+func (c *compiler) resolve(name string) (Opcode, int, bool) {
+	for i := len(c.params) - 1; i >= 0; i-- {
+		if c.params[i] == name {
+			return OpLocal, i, true
+		}
+	}
+
+	for i, n := range c.captured {
+		if n == name {
+			return OpCaptured, i, true
+		}
+	}
+
+	for p := c.parent; p != nil; p = p.parent {
+		if p.isLocal(name) {
+			c.captured = append(c.captured, name)
+			return OpCaptured, len(c.captured) - 1, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func (c *compiler) isLocal(name string) bool {
+	for _, n := range c.params {
+		if n == name {
+			return true
+		}
+	}
+	for _, n := range c.captured {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// syntax returns syntax bound to a global variable named by head, the
+// variable may be an alias like (define my-if if)
+func (c *compiler) syntax(head Object) (*SyntaxObject, bool) {
+	if head.Type() != TypeSymbol {
+		return nil, false
+	}
+
+	name := head.StringValue()
+	if _, _, found := c.resolve(name); found {
+		return nil, false
+	}
+
+	o, found := c.vm.Lookup(name)
+	if !found {
+		return nil, false
+	}
+
+	syntax, ok := o.(*SyntaxObject)
+	return syntax, ok
+}
+
+func (c *compiler) compileList(form Object, tail bool) {
+	head := form.Car()
+	args := listToSlice(form.Cdr())
+
+	if syntax, found := c.syntax(head); found {
+		switch syntax.name {
+		case "if":
+			c.compileIf(args, tail)
+		case "define":
+			c.compileDefine(args)
+		case "lambda":
+			c.compileLambda(args)
+		default:
+			// unknown syntax gets unevaluated arguments
+			c.compileSymbol(head)
+			for _, arg := range args {
+				c.emit(OpConst, c.constant(arg), 0)
+			}
+			c.emitCall(len(args), tail)
+		}
+		return
+	}
+
+	// builtin inlined by optimizer is called without checking
+	if isFunction(head) {
+		c.emit(OpConst, c.constant(head), 0)
+		for _, arg := range args {
+			c.compile(arg, false)
+		}
+		c.emitCall(len(args), tail)
+		return
+	}
+
+	// lists which do not start by a function evaluate to themselves
+	if head.Type() != TypeSymbol && head.Type() != TypeCons {
+		c.emit(OpConst, c.constant(form), 0)
+		return
+	}
+
+	c.compile(head, false)
+	check := c.emit(OpCheckCall, c.constant(form), 0)
+	for _, arg := range args {
+		c.compile(arg, false)
+	}
+	c.emitCall(len(args), tail)
+	c.patch(check)
+}
+
+func (c *compiler) emitCall(numArgs int, tail bool) {
+	if tail {
+		c.emit(OpTailCall, numArgs, 0)
+	} else {
+		c.emit(OpCall, numArgs, 0)
+	}
+}
+
+func (c *compiler) compileIf(args []Object, tail bool) {
+	if len(args) != 3 {
+		Error(errors.New("if operator expects 3 argument"))
+		c.emit(OpVoid, 0, 0)
+		return
+	}
+
+	c.compile(args[0], false)
+	jumpElse := c.emit(OpJumpIfFalse, 0, 0)
+	c.compile(args[1], tail)
+	jumpEnd := c.emit(OpJump, 0, 0)
+	c.patch(jumpElse)
+	c.compile(args[2], tail)
+	c.patch(jumpEnd)
+}
+
+func (c *compiler) compileDefine(args []Object) {
+	if len(args) != 2 {
+		Error(errors.New("define operator expects 2 argument"))
+		c.emit(OpVoid, 0, 0)
+		return
+	}
+
+	c.compile(args[1], false)
+	c.emit(OpDefine, c.global(args[0].StringValue()), 0)
+}
+
+func (c *compiler) compileLambda(args []Object) {
+	params, ok := lambdaParams(args)
+	if !ok {
+		c.emit(OpVoid, 0, 0)
+		return
+	}
+
+	fc := &compiler{
+		vm:       c.vm,
+		parent:   c,
+		scope:    c.scope,
+		name:     "lambda",
+		params:   params,
+		position: c.position,
+	}
+
+	body := args[1:]
+	if len(body) == 0 {
+		fc.emit(OpVoid, 0, 0)
+	}
+	for i, form := range body {
+		if i > 0 {
+			fc.emit(OpPop, 0, 0)
+		}
+		fc.compile(form, i == len(body)-1)
+	}
+	fc.emit(OpReturn, 0, 0)
+
+	code := c.constant(fc.code())
+	for _, name := range fc.captured {
+		op, index, _ := c.resolve(name)
+		c.emit(op, index, 0)
+	}
+	c.emit(OpClosure, code, len(fc.captured))
+}
+
+// lambdaParams returns names of parameters from arguments of lambda
+func lambdaParams(args []Object) ([]string, bool) {
+	if len(args) == 0 {
+		Error(errors.New("lambda operator expects parameters"))
+		return nil, false
+	}
+
+	if args[0].Type() != TypeCons && args[0].Type() != TypeNil {
+		Error(errors.New("lambda operator expects list of parameters"))
+		return nil, false
+	}
+
+	params := make([]string, 0)
+	for _, param := range listToSlice(args[0]) {
+		if param.Type() != TypeSymbol {
+			Error(errors.New("lambda parameters have to be symbols"))
+			return nil, false
+		}
+		params = append(params, param.StringValue())
+	}
+
+	return params, true
+}
+
+func listToSlice(list Object) []Object {
+	items := make([]Object, 0)
+	for ; list.Type() == TypeCons; list = list.Cdr() {
+		items = append(items, list.Car())
+	}
+	return items
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+// escape unwinds the Go stack up to call/cc which created continuation
+type escape struct {
+	continuation *ContinuationObject
+}
+
+// builtinCallCC calls function with continuation of the call/cc. Calling the
+// continuation returns its argument from call/cc, even from nested calls.
+func builtinCallCC(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("call/cc operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	function := vm.Stack().Pop()
+	if !isFunction(function) {
+		Error(errors.New("call/cc operator expects function"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	scope := vm.OpenScope()
+	defer scope.Close()
+	scope.Root(function)
+	k := scope.Root(NewContinuationObject(vm.current, vm.runningFinalizers).Allocate(vm)).(*ContinuationObject)
+
+	return vm.callWithContinuation(function, k)
+}
+
+// callWithContinuation calls function with k and returns its result or the
+// value k was called with. State of VM is restored like Try does.
+func (v *VM) callWithContinuation(function Object, k *ContinuationObject) (result Object) {
+	c := v.checkpoint()
+	k.active = true
+
+	defer func() {
+		k.active = false
+
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		e, ok := r.(escape)
+		if !ok || e.continuation != k {
+			panic(r)
+		}
+
+		v.rollback(c)
+		result = k.value
+		k.value = nil
+	}()
+
+	v.stack.Push(k)
+	return function.EvaluateFunction(1)
+}
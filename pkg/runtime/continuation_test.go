@@ -0,0 +1,121 @@
+package runtime_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+var evalModes = []runtime.EvalMode{runtime.ModeTree, runtime.ModeBytecode}
+
+func TestCallCCEscapesNestedCalls(t *testing.T) {
+	src := `
+(define find (lambda (n limit k) (if (= n limit) (k n) (+ 1 (find (+ n 1) limit k)))))
+(call/cc (lambda (k) (find 0 500 k)))
+(+ 1 (call/cc (lambda (k) (+ 10 (k 1)))))
+(call/cc (lambda (k) 3))
+`
+	want := "\n500\n2\n3\n"
+
+	for _, mode := range evalModes {
+		if got := run(t, src, runtime.WithEvalMode(mode)); got != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+}
+
+// hostApply is a builtin which calls function with continuation through
+// VM.Apply, so the continuation escapes through the VM.Try inside Apply
+func hostApply(numArgs int, vm *runtime.VM) runtime.Object {
+	scope := vm.OpenScope()
+	defer scope.Close()
+
+	k := scope.Root(vm.Stack().Pop())
+	function := scope.Root(vm.Stack().Pop())
+
+	result, err := vm.Apply(function, k)
+	if err != nil {
+		runtime.Error(err)
+	}
+	return result
+}
+
+func TestCallCCEscapesThroughTry(t *testing.T) {
+	src := `
+(+ 1 (call/cc (lambda (k) (host-apply (lambda (k) (+ 10 (k 5))) k))))
+(+ 1 2)
+`
+	want := "6\n3\n"
+
+	for _, mode := range evalModes {
+		for _, gcMode := range gcModes {
+			var out bytes.Buffer
+			vm := runtime.NewVM(
+				runtime.WithEvalMode(mode),
+				runtime.WithGCMode(gcMode),
+				runtime.WithGCStress(true),
+				runtime.WithStdout(&out),
+				runtime.WithStderr(&out),
+			)
+			vm.Define("host-apply", runtime.NewFunctionObject("host-apply", hostApply).Allocate(vm))
+			runVM(t, vm, src)
+
+			if got := out.String(); got != want {
+				t.Errorf("%s %s: got %q, want %q", mode, gcMode, got, want)
+			}
+			for _, err := range vm.Violations() {
+				t.Errorf("%s %s: %v", mode, gcMode, err)
+			}
+		}
+	}
+}
+
+func TestCallCCAfterExtent(t *testing.T) {
+	src := `
+(define saved (call/cc (lambda (k) k)))
+(saved 1)
+`
+	want := "error: continuation can not be called after call/cc returned"
+
+	for _, mode := range evalModes {
+		if got := run(t, src, runtime.WithEvalMode(mode)); !strings.Contains(got, want) {
+			t.Errorf("%s: %q does not contain %q", mode, got, want)
+		}
+	}
+}
+
+func TestCallCCFromTaskAndCoroutine(t *testing.T) {
+	src := `
+(call/cc (lambda (k) (receive (spawn (lambda () (k 1))))))
+(call/cc (lambda (k) (resume (make-coroutine (lambda () (k 1))))))
+`
+
+	for _, mode := range evalModes {
+		got := run(t, src, runtime.WithEvalMode(mode))
+		for _, want := range []string{
+			"error: task: continuation can not be called by another task or finalizer",
+			"error: coroutine: continuation can not be called by another task or finalizer",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %q does not contain %q", mode, got, want)
+			}
+		}
+	}
+}
+
+func TestCallCCInsideCoroutine(t *testing.T) {
+	src := `
+(define co (make-coroutine (lambda (x) (call/cc (lambda (k) (+ (yield x) (k 5)))))))
+(resume co 1)
+(resume co 2)
+`
+	want := "\n1\n5\n"
+
+	for _, mode := range evalModes {
+		if got := run(t, src, runtime.WithEvalMode(mode)); got != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+}
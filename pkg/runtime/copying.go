@@ -0,0 +1,84 @@
+package runtime
+
+// copying is a Cheney style semispace collector. Live objects are copied from
+// the current heap (from-space) into to-space in breadth first order and the
+// heap is replaced by to-space, so live objects end up packed without holes.
+// Objects reference each other by Go pointers which act as handles, only the
+// slot of each object changes and is looked up through forwarding addresses.
+type copying struct {
+	threshold int
+}
+
+func newCopying() *copying {
+	return &copying{
+		threshold: 10,
+	}
+}
+
+func (c *copying) shouldCollect(v *VM) bool {
+	return v.heap.Objects() >= c.threshold
+}
+
+func (c *copying) collect(v *VM, full bool) (bool, bool) {
+	from := v.heap
+	to := make([]Object, 0, from.Capacity())
+
+	// forwarding holds to-space slot for every copied from-space slot
+	forwarding := make([]int, from.Capacity())
+	for i := range forwarding {
+		forwarding[i] = -1
+	}
+
+	evacuate := func(o Object) {
+		if o == nil {
+			return
+		}
+
+		blockIndex, found := from.BlockIndex(o)
+		if !found || forwarding[blockIndex] >= 0 {
+			return
+		}
+
+		forwarding[blockIndex] = len(to)
+		to = append(to, o)
+	}
+
+	scan := 0
+	scanAll := func() {
+		for ; scan < len(to); scan++ {
+			for _, ref := range to[scan].References() {
+				evacuate(ref)
+			}
+		}
+	}
+
+	v.roots(evacuate)
+	scanAll()
+
+	v.queueFinalizers(func(o Object) bool {
+		blockIndex, _ := from.BlockIndex(o)
+		return forwarding[blockIndex] >= 0
+	}, evacuate)
+	scanAll()
+
+	// objects which were not forwarded are garbage
+	for blockIndex, o := range from.memory {
+		if o != nil && forwarding[blockIndex] < 0 {
+			v.FreeObject(blockIndex)
+		}
+	}
+
+	from.replace(to)
+
+	c.threshold = v.heap.Objects() * 2
+
+	return true, true
+}
+
+func (c *copying) allocated(v *VM, o Object, blockIndex int) {}
+
+func (c *copying) writeBarrier(v *VM, parent, child Object) {}
+
+func (c *copying) gcThreshold() int {
+	return c.threshold
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import "testing"
+
+func TestCopyingRenumbersLiveObjects(t *testing.T) {
+	vm := NewVM(WithGCMode(GCCopying))
+	c := vm.collector.(*copying)
+
+	garbage := make([]Object, 0)
+	for i := 0; i < 3; i++ {
+		garbage = append(garbage, newCons(vm))
+	}
+
+	// child is reachable only through parent
+	child := newCons(vm)
+	vm.Stack().Push(child)
+	vm.Stack().Push(NewNilObject().Allocate(vm))
+	parent := NewConsObject(vm.Stack()).Allocate(vm)
+	vm.Stack().Push(parent)
+
+	for i := 0; i < 3; i++ {
+		garbage = append(garbage, newCons(vm))
+	}
+
+	c.collect(vm, true)
+
+	for _, o := range garbage {
+		if _, found := vm.heap.BlockIndex(o); found {
+			t.Error("unreachable object is copied")
+		}
+	}
+
+	for _, o := range []Object{parent, child} {
+		blockIndex, found := vm.heap.BlockIndex(o)
+		if !found {
+			t.Fatal("reachable object is not copied")
+		}
+		if vm.heap.Get(blockIndex) != o {
+			t.Errorf("slot %d does not hold the object forwarded to it", blockIndex)
+		}
+		if blockIndex >= vm.heap.Objects() {
+			t.Errorf("object copied to slot %d above %d live objects", blockIndex, vm.heap.Objects())
+		}
+	}
+
+	if parent.Car() != child {
+		t.Error("reference is not preserved by copying")
+	}
+	if f := vm.heap.Fragmentation(); f != 0 {
+		t.Errorf("fragmentation %.2f after copying", f)
+	}
+}
@@ -0,0 +1,281 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+type coroutineState int
+
+const (
+	coroutineNew coroutineState = iota
+	coroutineSuspended
+	coroutineRunning
+	coroutineDone
+)
+
+// stopCoroutine unwinds goroutine of suspended coroutine which was collected
+type stopCoroutine struct{}
+
+// resumeCoroutine runs coroutine until it yields or returns and reports
+// whether it returned. Value is returned by yield in the coroutine, the first
+// resume passes it to the function instead. Control is handed between
+// goroutines without releasing the VM lock.
+func (v *VM) resumeCoroutine(c *CoroutineObject, value Object) (Object, bool) {
+	switch c.state {
+	case coroutineRunning:
+		Error(errors.New("coroutine is already running"))
+	case coroutineDone:
+		Error(errors.New("coroutine is finished"))
+	}
+
+	c.value = value
+	if value != nil {
+		v.WriteBarrier(c, value)
+	}
+
+	t := v.current
+	t.save(v)
+	if c.state == coroutineNew {
+		c.task = newTask()
+		c.task.coroutine = c
+	}
+
+	// coroutine runs with the context and steps of the task resuming it
+	c.task.evaluating = t.evaluating
+	c.task.ctx = t.ctx
+	c.task.steps = t.steps
+
+	if c.state == coroutineNew {
+		c.state = coroutineRunning
+		go v.runCoroutine(c)
+	} else {
+		c.state = coroutineRunning
+		c.in <- true
+	}
+	<-c.out
+	t.steps = v.steps
+	v.resume(t)
+
+	if c.err != nil {
+		err := c.err
+		c.err = nil
+		Error(errors.Wrap(err, "coroutine"))
+	}
+
+	value = c.value
+	c.value = nil
+	return value, c.state == coroutineDone
+}
+
+func (v *VM) runCoroutine(c *CoroutineObject) {
+	v.resume(c.task)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stopCoroutine); !ok {
+				panic(r)
+			}
+		}
+		c.task = nil
+		c.state = coroutineDone
+		c.out <- struct{}{}
+	}()
+
+	numArgs := 0
+	if c.value != nil {
+		v.stack.Push(c.value)
+		numArgs = 1
+	}
+	c.value = nil
+
+	var result Object
+	c.err = v.Try(func() {
+		result = c.function.EvaluateFunction(numArgs)
+	})
+
+	c.value = result
+	if result != nil {
+		v.WriteBarrier(c, result)
+	}
+}
+
+// yieldCoroutine suspends the running coroutine and hands value to the task
+// which resumed it. It returns value passed by the next resume.
+func (v *VM) yieldCoroutine(value Object) Object {
+	t := v.current
+	c := t.coroutine
+	if c == nil {
+		Error(errors.New("yield outside of coroutine"))
+	}
+
+	c.value = value
+	v.WriteBarrier(c, value)
+	c.state = coroutineSuspended
+
+	t.save(v)
+	c.out <- struct{}{}
+	resumed := <-c.in
+	v.resume(t)
+
+	if !resumed {
+		panic(stopCoroutine{})
+	}
+
+	value = c.value
+	c.value = nil
+	if value == nil {
+		return NewNilObject().Allocate(v)
+	}
+	return value
+}
+
+// freeCoroutine is called when coroutine is freed, its goroutine is stopped
+// after the collection finishes
+func (v *VM) freeCoroutine(c *CoroutineObject) {
+	if c.state == coroutineSuspended {
+		v.stopping = append(v.stopping, c)
+	}
+}
+
+// stopCoroutines unwinds goroutines of collected coroutines. The unwinding
+// only restores state of the coroutine task and does not allocate.
+func (v *VM) stopCoroutines() {
+	for len(v.stopping) > 0 {
+		c := v.stopping[0]
+		v.stopping = v.stopping[1:]
+
+		t := v.current
+		t.save(v)
+		c.in <- false
+		<-c.out
+		v.resume(t)
+	}
+}
+
+func builtinMakeCoroutine(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("make-coroutine operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	function := vm.Stack().Pop()
+	if !isFunction(function) {
+		Error(errors.New("make-coroutine operator expects function"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	return NewCoroutineObject(function).Allocate(vm)
+}
+
+// builtinResume returns value yielded by coroutine or returned by its
+// function
+func builtinResume(numArgs int, vm *VM) Object {
+	if numArgs != 1 && numArgs != 2 {
+		Error(errors.New("resume operator expects 1 or 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	var value Object
+	if numArgs == 2 {
+		value = vm.Stack().Pop()
+	}
+	c, ok := vm.Stack().Pop().(*CoroutineObject)
+	if !ok {
+		Error(errors.New("resume operator expects coroutine"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	scope := vm.OpenScope()
+	defer scope.Close()
+	scope.Root(c)
+
+	result, _ := vm.resumeCoroutine(c, value)
+	return result
+}
+
+func builtinYield(numArgs int, vm *VM) Object {
+	if numArgs != 1 {
+		Error(errors.New("yield operator expects 1 argument"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	return vm.yieldCoroutine(vm.Stack().Pop())
+}
+
+// builtinNext returns the next value yielded by generator, the default value
+// or F once the generator finished
+func builtinNext(numArgs int, vm *VM) Object {
+	if numArgs != 1 && numArgs != 2 {
+		Error(errors.New("next operator expects 1 or 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	var def Object
+	if numArgs == 2 {
+		def = vm.Stack().Pop()
+	}
+	c, ok := vm.Stack().Pop().(*CoroutineObject)
+	if !ok {
+		Error(errors.New("next operator expects generator"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	scope := vm.OpenScope()
+	defer scope.Close()
+	scope.Root(c)
+	if def != nil {
+		scope.Root(def)
+	}
+
+	if value, done := nextValue(vm, c); !done {
+		return value
+	}
+	if def != nil {
+		return def
+	}
+	return NewBoolObject(false).Allocate(vm)
+}
+
+// builtinTake returns list of at most n next values of generator
+func builtinTake(numArgs int, vm *VM) Object {
+	if numArgs != 2 {
+		Error(errors.New("take operator expects 2 arguments"))
+		vm.Stack().PopTimes(numArgs)
+		return NewVoidObject().Allocate(vm)
+	}
+
+	n := vm.Stack().Pop().IntegerValue()
+	c, ok := vm.Stack().Pop().(*CoroutineObject)
+	if !ok {
+		Error(errors.New("take operator expects generator"))
+		return NewVoidObject().Allocate(vm)
+	}
+
+	scope := vm.OpenScope()
+	defer scope.Close()
+	scope.Root(c)
+
+	count := 0
+	for ; count < n; count++ {
+		value, done := nextValue(vm, c)
+		if done {
+			break
+		}
+		vm.Stack().Push(value)
+	}
+
+	return NewList(vm, count)
+}
+
+// nextValue resumes generator unless it is finished, value returned by its
+// function is dropped
+func nextValue(vm *VM, c *CoroutineObject) (Object, bool) {
+	if c.state == coroutineDone {
+		return nil, true
+	}
+	return vm.resumeCoroutine(c, nil)
+}
@@ -0,0 +1,58 @@
+package runtime_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// suspended coroutine keeps a young hash table only in its environment while
+// minor collections run
+const suspendedCoroutine = `
+(define wb 0)
+(define co (make-coroutine (lambda () ((lambda (h) (define wb (make-weak-box h)) (yield 1) h) (make-hash-table)))))
+(gc)
+(resume co)
+(define churn (lambda (n) (if (= n 0) 0 (churn (- n 1)))))
+(churn 200)
+(weak-box-value wb)
+(resume co)
+`
+
+func TestSuspendedCoroutineIsTraced(t *testing.T) {
+	for _, mode := range gcModes {
+		out := run(t, suspendedCoroutine, runtime.WithGCMode(mode))
+		want := "\n\n\n1\n\n0\nhash-table\nhash-table\n"
+		if out != want {
+			t.Errorf("%s: got %q, want %q", mode, out, want)
+		}
+	}
+}
+
+const loopingCoroutine = `
+(define loop (lambda (n) (loop (+ n 1))))
+(resume (make-coroutine (lambda () (loop 0))))
+`
+
+func TestCoroutineInheritsLimits(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithEvalMode(runtime.ModeBytecode), runtime.WithStderr(ioutil.Discard))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := evalContext(ctx, t, vm, loopingCoroutine); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("context: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	vm = runtime.NewVM(
+		runtime.WithEvalMode(runtime.ModeBytecode),
+		runtime.WithStderr(ioutil.Discard),
+		runtime.WithLimits(runtime.Limits{MaxSteps: 10000}),
+	)
+	if err := evalContext(context.Background(), t, vm, loopingCoroutine); errors.Cause(err) != runtime.ErrStepLimit {
+		t.Errorf("steps: got %v, want %v", err, runtime.ErrStepLimit)
+	}
+}
@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Disassemble writes instructions and constant pool of compiled code and of
+// all functions nested in it
+func Disassemble(w io.Writer, code *CodeObject) error {
+	var b strings.Builder
+	disassemble(&b, code)
+
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "disassembling")
+}
+
+func disassemble(b *strings.Builder, code *CodeObject) {
+	fmt.Fprintf(b, "code %s", code.name)
+	if len(code.params) > 0 {
+		fmt.Fprintf(b, " (%s)", strings.Join(code.params, " "))
+	}
+	if len(code.captured) > 0 {
+		fmt.Fprintf(b, " captured (%s)", strings.Join(code.captured, " "))
+	}
+	b.WriteString("\n")
+
+	if len(code.globals) > 0 {
+		names := make([]string, len(code.globals))
+		for i, g := range code.globals {
+			names[i] = g.name
+		}
+		fmt.Fprintf(b, "globals: %s\n", strings.Join(names, " "))
+	}
+
+	if len(code.constants) > 0 {
+		b.WriteString("constants:\n")
+		for i, constant := range code.constants {
+			fmt.Fprintf(b, "\t%4d  %-8s %s\n", i, constant.Type(), constantValue(constant))
+		}
+	}
+
+	b.WriteString("instructions:\n")
+	for pc, instruction := range code.instructions {
+		position := Position{}
+		if pc < len(code.positions) {
+			position = code.positions[pc]
+		}
+
+		line := fmt.Sprintf("\t%4d  %-8s %-14s%s", pc, position, instruction.Op, operands(code, instruction))
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	for _, constant := range code.constants {
+		if nested, ok := constant.(*CodeObject); ok {
+			b.WriteString("\n")
+			disassemble(b, nested)
+		}
+	}
+}
+
+// operands formats operands of instruction with a comment describing them
+func operands(code *CodeObject, instruction Instruction) string {
+	a, bb := int(instruction.A), int(instruction.B)
+
+	constant := func(i int) string {
+		if i < 0 || i >= len(code.constants) {
+			return "?"
+		}
+		return constantValue(code.constants[i])
+	}
+
+	switch instruction.Op {
+	case OpConst:
+		return fmt.Sprintf("%-6d ; %s", a, constant(a))
+	case OpGlobal, OpDefine:
+		if a < len(code.globals) {
+			return fmt.Sprintf("%-6d ; %s", a, code.globals[a].name)
+		}
+		return fmt.Sprint(a)
+	case OpLocal:
+		if a < len(code.params) {
+			return fmt.Sprintf("%-6d ; %s", a, code.params[a])
+		}
+		return fmt.Sprint(a)
+	case OpCaptured:
+		if a < len(code.captured) {
+			return fmt.Sprintf("%-6d ; %s", a, code.captured[a])
+		}
+		return fmt.Sprint(a)
+	case OpJump, OpJumpIfFalse, OpCall, OpTailCall:
+		return fmt.Sprint(a)
+	case OpCheckCall:
+		return fmt.Sprintf("%d %-4d ; %s", a, bb, constant(a))
+	case OpClosure:
+		name := "?"
+		if a < len(code.constants) {
+			if nested, ok := code.constants[a].(*CodeObject); ok {
+				name = nested.name
+			}
+		}
+		return fmt.Sprintf("%d %-4d ; %s", a, bb, name)
+	}
+
+	return ""
+}
+
+func constantValue(o Object) string {
+	if f, ok := o.(*FunctionObject); ok {
+		return f.name
+	}
+	return heapDumpValue(o)
+}
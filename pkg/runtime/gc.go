@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+type GCMode int
+
+const (
+	GCMarkSweep GCMode = iota
+	GCGenerational
+	GCIncremental
+	GCCopying
+)
+
+var gcModeNames = map[GCMode]string{
+	GCMarkSweep:    "marksweep",
+	GCGenerational: "generational",
+	GCIncremental:  "incremental",
+	GCCopying:      "copying",
+}
+
+func ParseGCMode(name string) (GCMode, error) {
+	for mode, modeName := range gcModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return 0, errors.Errorf("unknown gc mode %s", name)
+}
+
+func (m GCMode) String() string {
+	return gcModeNames[m]
+}
+
+// collector is a garbage collection strategy used by VM
+type collector interface {
+	// shouldCollect reports whether collection should run before next allocation
+	shouldCollect(v *VM) bool
+	// collect performs a step of collection and reports whether a collection
+	// cycle was finished and whether the cycle collected the whole heap, full
+	// forces finishing collection of the whole heap
+	collect(v *VM, full bool) (done, major bool)
+	// allocated is called after object was stored in the heap
+	allocated(v *VM, o Object, blockIndex int)
+	// writeBarrier is called when reference to child is stored into parent
+	writeBarrier(v *VM, parent, child Object)
+	// threshold returns the number of objects triggering next full collection
+	gcThreshold() int
+}
+
+func newCollector(mode GCMode, sliceBudget int) collector {
+	switch mode {
+	case GCGenerational:
+		return newGenerational()
+	case GCIncremental:
+		return newIncremental(sliceBudget)
+	case GCCopying:
+		return newCopying()
+	default:
+		return newMarkSweep()
+	}
+}
+
+// roots calls f for every object directly reachable by VM
+func (v *VM) roots(f func(Object)) {
+	for _, g := range v.variables {
+		if g.value != nil {
+			f(g.value)
+		}
+	}
+
+	v.evaluationRoots(func(_ string, _ int, o Object) {
+		f(o)
+	})
+}
+
+// evaluationRoots calls f for every root except global variables. Name and
+// index tell where the root is held, index is -1 when name is enough.
+func (v *VM) evaluationRoots(f func(name string, index int, o Object)) {
+	for i, o := range v.stack.stack {
+		f("stack", i, o)
+	}
+
+	for i, o := range v.handles {
+		f("handles", i, o)
+	}
+
+	if v.env != nil {
+		f("env", -1, v.env)
+	}
+
+	v.taskRoots(f)
+	v.finalizerRoots(f)
+}
+
+// trace marks every object reachable from gray objects. Only references
+// accepted by filter are followed.
+func trace(gray []Object, filter func(Object) bool) {
+	for len(gray) > 0 {
+		l := len(gray)
+		o := gray[l-1]
+		gray = gray[:l-1]
+
+		for _, ref := range o.References() {
+			if ref == nil || ref.IsMarked() || !filter(ref) {
+				continue
+			}
+			ref.Mark()
+			gray = append(gray, ref)
+		}
+	}
+}
+
+func traceAll(Object) bool {
+	return true
+}
@@ -0,0 +1,110 @@
+package runtime
+
+const gcNurseryObjects = 64
+
+// generational collects the nursery of young objects on every minor collection
+// and promotes all survivors to the old generation. The whole heap is collected
+// once the old generation doubles or the heap is full.
+type generational struct {
+	// young maps objects in the nursery to their heap slots
+	young map[Object]int
+	// remembered holds old objects which may reference young objects
+	remembered map[Object]bool
+
+	oldThreshold int
+}
+
+func newGenerational() *generational {
+	return &generational{
+		young:        make(map[Object]int),
+		remembered:   make(map[Object]bool),
+		oldThreshold: gcNurseryObjects,
+	}
+}
+
+func (g *generational) shouldCollect(v *VM) bool {
+	return len(g.young) >= gcNurseryObjects
+}
+
+func (g *generational) collect(v *VM, full bool) (bool, bool) {
+	if full || v.heap.Objects()-len(g.young) >= g.oldThreshold {
+		g.collectMajor(v)
+		return true, true
+	}
+
+	g.collectMinor(v)
+	return true, false
+}
+
+func (g *generational) collectMinor(v *VM) {
+	gray := make([]Object, 0)
+	shade := func(o Object) {
+		if g.isYoung(o) && !o.IsMarked() {
+			o.Mark()
+			gray = append(gray, o)
+		}
+	}
+
+	v.roots(shade)
+	for o := range g.remembered {
+		for _, ref := range o.References() {
+			shade(ref)
+		}
+	}
+
+	trace(gray, g.isYoung)
+
+	gray = gray[:0]
+	v.queueFinalizers(func(o Object) bool {
+		return !g.isYoung(o) || o.IsMarked()
+	}, shade)
+	trace(gray, g.isYoung)
+
+	for o, blockIndex := range g.young {
+		if o.IsMarked() {
+			o.UnMark()
+		} else {
+			v.FreeObject(blockIndex)
+		}
+	}
+
+	g.promote()
+}
+
+func (g *generational) collectMajor(v *VM) {
+	v.mark()
+	v.queueFinalizers(isMarked, v.markFrom)
+	v.sweep()
+
+	g.promote()
+	g.oldThreshold = v.heap.Objects() * 2
+	if g.oldThreshold < gcNurseryObjects {
+		g.oldThreshold = gcNurseryObjects
+	}
+}
+
+// promote moves every surviving young object to the old generation. Old
+// objects then only reference old objects, so the remembered set is empty.
+func (g *generational) promote() {
+	g.young = make(map[Object]int)
+	g.remembered = make(map[Object]bool)
+}
+
+func (g *generational) isYoung(o Object) bool {
+	_, found := g.young[o]
+	return found
+}
+
+func (g *generational) allocated(v *VM, o Object, blockIndex int) {
+	g.young[o] = blockIndex
+}
+
+func (g *generational) writeBarrier(v *VM, parent, child Object) {
+	if !g.isYoung(parent) && g.isYoung(child) {
+		g.remembered[parent] = true
+	}
+}
+
+func (g *generational) gcThreshold() int {
+	return g.oldThreshold
+}
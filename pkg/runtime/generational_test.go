@@ -0,0 +1,89 @@
+package runtime
+
+import "testing"
+
+// newCons allocates cons of two nils
+func newCons(vm *VM) *ConsObject {
+	vm.Stack().Push(NewNilObject().Allocate(vm))
+	vm.Stack().Push(NewNilObject().Allocate(vm))
+	return NewConsObject(vm.Stack()).Allocate(vm).(*ConsObject)
+}
+
+// newGenerationalVM returns VM with empty nursery
+func newGenerationalVM() (*VM, *generational) {
+	vm := NewVM(WithGCMode(GCGenerational))
+	g := vm.collector.(*generational)
+	g.collectMinor(vm)
+	return vm, g
+}
+
+func TestGenerationalPromotion(t *testing.T) {
+	vm, g := newGenerationalVM()
+
+	live := newCons(vm)
+	vm.Stack().Push(live)
+	liveIndex := g.young[live]
+	garbage := newCons(vm)
+	garbageIndex := g.young[garbage]
+
+	if !g.isYoung(live) || !g.isYoung(garbage) {
+		t.Fatal("new objects are not in the nursery")
+	}
+
+	g.collectMinor(vm)
+
+	if g.isYoung(live) {
+		t.Error("surviving object is not promoted")
+	}
+	if vm.heap.Get(liveIndex) != live {
+		t.Error("rooted young object is freed")
+	}
+	if vm.heap.Get(garbageIndex) != nil {
+		t.Error("unreachable young object is not freed")
+	}
+}
+
+func TestGenerationalRememberedSet(t *testing.T) {
+	vm, g := newGenerationalVM()
+
+	parent := newCons(vm)
+	vm.Stack().Push(parent)
+	g.collectMinor(vm)
+
+	child := newCons(vm)
+	childIndex := g.young[child]
+	parent.car = child
+	vm.WriteBarrier(parent, child)
+
+	if !g.remembered[parent] {
+		t.Fatal("old object referencing young object is not remembered")
+	}
+
+	g.collectMinor(vm)
+
+	if vm.heap.Get(childIndex) != child {
+		t.Error("young object referenced only by old object is freed")
+	}
+	if len(g.remembered) != 0 {
+		t.Error("remembered set is not cleared after promotion")
+	}
+}
+
+func TestGenerationalResizesAfterMajor(t *testing.T) {
+	vm, g := newGenerationalVM()
+	g.oldThreshold = vm.heap.Capacity()
+	capacity := vm.heap.Capacity()
+
+	// minor collections run while live objects fill the heap
+	for vm.heap.Objects() < capacity-1 {
+		vm.Stack().Push(newCons(vm))
+	}
+	if vm.heap.Capacity() != capacity {
+		t.Errorf("heap resized to %d by minor collection", vm.heap.Capacity())
+	}
+
+	vm.GC()
+	if vm.heap.Capacity() <= capacity {
+		t.Error("heap does not grow after major collection")
+	}
+}
@@ -0,0 +1,29 @@
+package runtime
+
+// HandleScope keeps objects held only by Go variables alive. Objects rooted
+// in the scope are GC roots until the scope is closed. Scopes have to be
+// closed in reverse order of opening, usually by defer.
+type HandleScope struct {
+	vm   *VM
+	base int
+}
+
+func (v *VM) OpenScope() *HandleScope {
+	return &HandleScope{
+		vm:   v,
+		base: len(v.handles),
+	}
+}
+
+// Root keeps o alive until the scope is closed and returns it
+func (s *HandleScope) Root(o Object) Object {
+	s.vm.handles = append(s.vm.handles, o)
+	return o
+}
+
+func (s *HandleScope) Close() {
+	for i := s.base; i < len(s.vm.handles); i++ {
+		s.vm.handles[i] = nil
+	}
+	s.vm.handles = s.vm.handles[:s.base]
+}
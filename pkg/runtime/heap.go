@@ -0,0 +1,159 @@
+package runtime
+
+const (
+	HeapInitialObjects = 256
+
+	// heap grows when more than heapGrowRatio of capacity survives a collection
+	// and shrinks when less than heapShrinkRatio survives
+	heapGrowRatio   = 0.75
+	heapShrinkRatio = 0.25
+)
+
+// Heap is simulated memory of object slots. Free slots are kept in a free list
+// so allocation does not depend on the heap size.
+type Heap struct {
+	memory []Object
+	free   []int
+	// index maps objects to their slots
+	index map[Object]int
+
+	objects int
+}
+
+func NewHeap(capacity int) *Heap {
+	h := &Heap{
+		memory:  make([]Object, 0),
+		free:    make([]int, 0, capacity),
+		index:   make(map[Object]int),
+		objects: 0,
+	}
+	h.grow(capacity)
+
+	return h
+}
+
+func (h *Heap) Capacity() int {
+	return len(h.memory)
+}
+
+func (h *Heap) Objects() int {
+	return h.objects
+}
+
+func (h *Heap) IsFull() bool {
+	return len(h.free) == 0
+}
+
+func (h *Heap) Get(blockIndex int) Object {
+	return h.memory[blockIndex]
+}
+
+// BlockIndex returns slot of the object or false if it is not in the heap
+func (h *Heap) BlockIndex(o Object) (int, bool) {
+	blockIndex, found := h.index[o]
+	return blockIndex, found
+}
+
+// Fragmentation returns the ratio of free slots below the highest used slot
+func (h *Heap) Fragmentation() float64 {
+	top := len(h.memory) - 1
+	for top >= 0 && h.memory[top] == nil {
+		top--
+	}
+
+	if top < 0 {
+		return 0
+	}
+
+	holes := top + 1 - h.objects
+	return float64(holes) / float64(top+1)
+}
+
+// Allocate stores object in a free slot and returns its index. Caller has to
+// make sure the heap is not full.
+func (h *Heap) Allocate(o Object) int {
+	l := len(h.free)
+	blockIndex := h.free[l-1]
+	h.free = h.free[:l-1]
+
+	h.memory[blockIndex] = o
+	h.index[o] = blockIndex
+	h.objects++
+
+	return blockIndex
+}
+
+func (h *Heap) Free(blockIndex int) {
+	delete(h.index, h.memory[blockIndex])
+	h.memory[blockIndex] = nil
+	h.free = append(h.free, blockIndex)
+	h.objects--
+}
+
+// replace drops all objects and stores live objects packed at the start of
+// the heap, capacity stays the same
+func (h *Heap) replace(live []Object) {
+	capacity := h.Capacity()
+
+	h.memory = make([]Object, 0, capacity)
+	h.free = h.free[:0]
+	h.index = make(map[Object]int)
+	h.objects = 0
+
+	for _, o := range live {
+		h.index[o] = len(h.memory)
+		h.memory = append(h.memory, o)
+		h.objects++
+	}
+
+	h.grow(capacity - len(live))
+}
+
+// Resize grows or shrinks the heap according to the amount of live objects.
+// It is called after collection.
+func (h *Heap) Resize() {
+	capacity := h.Capacity()
+
+	if float64(h.objects) > float64(capacity)*heapGrowRatio {
+		h.grow(capacity)
+		return
+	}
+
+	if capacity > HeapInitialObjects && float64(h.objects) < float64(capacity)*heapShrinkRatio {
+		h.shrink(capacity / 2)
+	}
+}
+
+func (h *Heap) grow(n int) {
+	from := len(h.memory)
+	h.memory = append(h.memory, make([]Object, n)...)
+
+	// push in reverse so lower slots are handed out first
+	for i := len(h.memory) - 1; i >= from; i-- {
+		h.free = append(h.free, i)
+	}
+}
+
+// shrink drops the free tail of the heap down to capacity. Objects never move,
+// so the heap can only shrink when the tail is empty.
+func (h *Heap) shrink(capacity int) {
+	if capacity < HeapInitialObjects {
+		capacity = HeapInitialObjects
+	}
+
+	for _, o := range h.memory[capacity:] {
+		if o != nil {
+			return
+		}
+	}
+
+	h.memory = h.memory[:capacity:capacity]
+
+	free := h.free[:0]
+	for _, blockIndex := range h.free {
+		if blockIndex < capacity {
+			free = append(free, blockIndex)
+		}
+	}
+	h.free = free
+}
@@ -0,0 +1,27 @@
+package runtime_test
+
+import (
+	"fmt"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// BenchmarkAllocate allocates and frees an object in heaps which are half
+// full, the time per operation should not depend on the heap size
+func BenchmarkAllocate(b *testing.B) {
+	for _, size := range []int{1 << 8, 1 << 12, 1 << 16, 1 << 20} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			heap := runtime.NewHeap(size)
+			for i := 0; i < size/2; i++ {
+				heap.Allocate(runtime.NewSymbolObject("live"))
+			}
+
+			o := runtime.NewSymbolObject("new")
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				heap.Free(heap.Allocate(o))
+			}
+		})
+	}
+}
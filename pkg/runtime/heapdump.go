@@ -0,0 +1,192 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// longer object values are truncated in heap dumps
+const heapDumpValueLength = 64
+
+type HeapSnapshot struct {
+	Capacity int          `json:"capacity"`
+	Roots    []HeapRoot   `json:"roots"`
+	Objects  []HeapObject `json:"objects"`
+}
+
+type HeapRoot struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+// HeapObject describes an object in the heap, its ID is the heap slot
+type HeapObject struct {
+	ID         int    `json:"id"`
+	Type       string `json:"type"`
+	Size       int    `json:"size"`
+	Value      string `json:"value"`
+	References []int  `json:"references"`
+	// RootPath is the shortest path from a root, it is empty for garbage
+	RootPath []string `json:"rootPath"`
+}
+
+// Snapshot returns description of every object in the heap
+func (v *VM) Snapshot() *HeapSnapshot {
+	snapshot := &HeapSnapshot{
+		Capacity: v.heap.Capacity(),
+		Roots:    make([]HeapRoot, 0),
+		Objects:  make([]HeapObject, 0, v.heap.Objects()),
+	}
+
+	names := make([]string, 0, len(v.variables))
+	for name, g := range v.variables {
+		if g.value != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	addRoot := func(name string, o Object) {
+		if id, found := v.heap.BlockIndex(o); found {
+			snapshot.Roots = append(snapshot.Roots, HeapRoot{Name: name, ID: id})
+		}
+	}
+	// the same roots as collectors use, global variables sorted by name
+	for _, name := range names {
+		addRoot(name, v.variables[name].value)
+	}
+	v.evaluationRoots(func(name string, index int, o Object) {
+		if index >= 0 {
+			name += "[" + strconv.Itoa(index) + "]"
+		}
+		addRoot(name, o)
+	})
+
+	paths := v.rootPaths(snapshot.Roots)
+
+	for id, o := range v.heap.memory {
+		if o == nil {
+			continue
+		}
+
+		references := make([]int, 0)
+		for _, ref := range o.References() {
+			if refID, found := v.heap.BlockIndex(ref); found {
+				references = append(references, refID)
+			}
+		}
+
+		snapshot.Objects = append(snapshot.Objects, HeapObject{
+			ID:         id,
+			Type:       o.Type().String(),
+			Size:       1,
+			Value:      heapDumpValue(o),
+			References: references,
+			RootPath:   paths[id],
+		})
+	}
+
+	return snapshot
+}
+
+// rootPaths finds the shortest path from roots to every reachable object
+func (v *VM) rootPaths(roots []HeapRoot) map[int][]string {
+	paths := make(map[int][]string)
+	queue := make([]int, 0)
+
+	for _, root := range roots {
+		if _, found := paths[root.ID]; !found {
+			paths[root.ID] = []string{root.Name, strconv.Itoa(root.ID)}
+			queue = append(queue, root.ID)
+		}
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, ref := range v.heap.Get(id).References() {
+			refID, found := v.heap.BlockIndex(ref)
+			if !found {
+				continue
+			}
+			if _, visited := paths[refID]; visited {
+				continue
+			}
+
+			path := append([]string(nil), paths[id]...)
+			paths[refID] = append(path, strconv.Itoa(refID))
+			queue = append(queue, refID)
+		}
+	}
+
+	return paths
+}
+
+func heapDumpValue(o Object) string {
+	value := o.String()
+	if len(value) > heapDumpValueLength {
+		value = value[:heapDumpValueLength] + "..."
+	}
+	return value
+}
+
+// DumpHeap writes heap snapshot as JSON
+func (v *VM) DumpHeap(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return errors.Wrap(encoder.Encode(v.Snapshot()), "dumping heap")
+}
+
+// DumpHeapDot writes heap snapshot as a Graphviz DOT graph
+func (v *VM) DumpHeapDot(w io.Writer) error {
+	snapshot := v.Snapshot()
+
+	var b strings.Builder
+	b.WriteString("digraph heap {\n")
+
+	for i, root := range snapshot.Roots {
+		fmt.Fprintf(&b, "\troot%d [shape=box, label=%q];\n", i, root.Name)
+		fmt.Fprintf(&b, "\troot%d -> o%d;\n", i, root.ID)
+	}
+
+	for _, o := range snapshot.Objects {
+		style := ""
+		if len(o.RootPath) == 0 {
+			style = ", style=dashed"
+		}
+
+		fmt.Fprintf(&b, "\to%d [label=%q%s];\n", o.ID, fmt.Sprintf("%d: %s %s", o.ID, o.Type, o.Value), style)
+		for _, ref := range o.References {
+			fmt.Fprintf(&b, "\to%d -> o%d;\n", o.ID, ref)
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "dumping heap")
+}
+
+// DumpHeapFile writes heap snapshot into a file, files with .dot extension
+// are written as DOT graph and other files as JSON
+func (v *VM) DumpHeapFile(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return errors.Wrap(err, "dumping heap")
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(name, ".dot") {
+		return v.DumpHeapDot(file)
+	}
+	return v.DumpHeap(file)
+}
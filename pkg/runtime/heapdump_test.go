@@ -0,0 +1,37 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// objects held only by the environment of running lambda are live
+func TestSnapshotRoots(t *testing.T) {
+	for _, mode := range []runtime.EvalMode{runtime.ModeTree, runtime.ModeBytecode} {
+		vm := runtime.NewVM(runtime.WithEvalMode(mode), runtime.WithStdout(ioutil.Discard))
+
+		var snapshot *runtime.HeapSnapshot
+		vm.Define("snapshot", runtime.NewFunctionObject("snapshot", func(numArgs int, vm *runtime.VM) runtime.Object {
+			vm.Stack().PopTimes(numArgs)
+			snapshot = vm.Snapshot()
+			return runtime.NewVoidObject().Allocate(vm)
+		}).Allocate(vm))
+		runVM(t, vm, "((lambda (h) (snapshot) h) (make-hash-table))")
+
+		found := false
+		for _, o := range snapshot.Objects {
+			if o.Type != "hash-table" {
+				continue
+			}
+			found = true
+			if o.RootPath == nil {
+				t.Errorf("%s: live hash table has no root path", mode)
+			}
+		}
+		if !found {
+			t.Errorf("%s: hash table is not in snapshot", mode)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package runtime
+
+// GCSliceBudget is the default number of objects scanned per incremental GC slice
+const GCSliceBudget = 32
+
+// incremental marks the heap in bounded slices interleaved with allocation.
+// White objects are unmarked, gray objects are marked and waiting in the gray
+// list and black objects are marked and scanned. Objects allocated during
+// marking are black and the write barrier shades white children of marked
+// parents, so no black object ever references a white one.
+type incremental struct {
+	marking bool
+	gray    []Object
+
+	budget    int
+	threshold int
+}
+
+func newIncremental(budget int) *incremental {
+	if budget <= 0 {
+		budget = GCSliceBudget
+	}
+
+	return &incremental{
+		marking:   false,
+		gray:      make([]Object, 0),
+		budget:    budget,
+		threshold: 10,
+	}
+}
+
+func (i *incremental) shouldCollect(v *VM) bool {
+	return i.marking || v.heap.Objects() >= i.threshold
+}
+
+func (i *incremental) collect(v *VM, full bool) (bool, bool) {
+	if !i.marking {
+		i.marking = true
+		v.roots(i.shade)
+
+		if !full {
+			return false, false
+		}
+	}
+
+	if !full && i.markSlice() {
+		return false, false
+	}
+
+	// roots are not guarded by the write barrier, rescan them before sweeping
+	v.roots(i.shade)
+	for i.markSlice() {
+	}
+
+	v.queueFinalizers(isMarked, i.shade)
+	for i.markSlice() {
+	}
+
+	v.sweep()
+
+	i.marking = false
+	i.threshold = v.heap.Objects() * 2
+
+	return true, true
+}
+
+// markSlice scans at most budget gray objects and reports whether some gray
+// objects are left
+func (i *incremental) markSlice() bool {
+	for n := 0; n < i.budget && len(i.gray) > 0; n++ {
+		l := len(i.gray)
+		o := i.gray[l-1]
+		i.gray = i.gray[:l-1]
+
+		for _, ref := range o.References() {
+			i.shade(ref)
+		}
+	}
+
+	return len(i.gray) > 0
+}
+
+func (i *incremental) shade(o Object) {
+	if o == nil || o.IsMarked() {
+		return
+	}
+
+	o.Mark()
+	i.gray = append(i.gray, o)
+}
+
+func (i *incremental) allocated(v *VM, o Object, blockIndex int) {
+	if !i.marking {
+		return
+	}
+
+	// allocate black, references stored on construction go through the barrier
+	o.Mark()
+	for _, ref := range o.References() {
+		i.shade(ref)
+	}
+}
+
+func (i *incremental) writeBarrier(v *VM, parent, child Object) {
+	if i.marking && parent.IsMarked() {
+		i.shade(child)
+	}
+}
+
+func (i *incremental) gcThreshold() int {
+	return i.threshold
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import "testing"
+
+// inHeap reports whether o is allocated in heap of vm
+func inHeap(vm *VM, o Object) bool {
+	for i := 0; i < vm.heap.Capacity(); i++ {
+		if vm.heap.Get(i) == o {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIncrementalWriteBarrier(t *testing.T) {
+	vm := NewVM(WithGCMode(GCIncremental))
+	inc := vm.collector.(*incremental)
+	if inc.marking {
+		inc.collect(vm, true)
+	}
+
+	parent := newCons(vm)
+	vm.Stack().Push(parent)
+
+	// child is reachable only through gray
+	child := newCons(vm)
+	vm.Stack().Push(child)
+	vm.Stack().Push(NewNilObject().Allocate(vm))
+	gray := NewConsObject(vm.Stack()).Allocate(vm).(*ConsObject)
+	vm.Stack().Push(gray)
+
+	// parent is black as if an earlier slice scanned it
+	parent.Mark()
+	inc.collect(vm, false)
+	if !inc.marking {
+		t.Fatal("marking did not start")
+	}
+
+	// move child from gray to black parent in the middle of marking
+	parent.car = child
+	vm.WriteBarrier(parent, child)
+	gray.car = parent.cdr
+
+	if !child.IsMarked() {
+		t.Error("write barrier did not shade white child of black parent")
+	}
+
+	inc.collect(vm, true)
+	if !inHeap(vm, child) {
+		t.Error("child stored into black parent during marking is freed")
+	}
+}
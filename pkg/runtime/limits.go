@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Errors which abort evaluation exceeding its limits. Deadline and
+// cancellation abort it with the error of the context.
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrDepthLimit = errors.New("recursion depth limit exceeded")
+	ErrHeapLimit  = errors.New("heap object limit exceeded")
+)
+
+// contextCheckInterval is the number of steps between checks of the context
+const contextCheckInterval = 1024
+
+// Limits bound every evaluation started by VM.EvalForm, VM.EvalContext,
+// VM.Apply or VM.ApplyContext. Zero means unlimited.
+type Limits struct {
+	// MaxSteps is the number of function calls, including tail calls
+	MaxSteps int
+	// MaxDepth is the number of nested calls of lambdas
+	MaxDepth int
+	// MaxObjects is the number of objects in the heap after a full collection
+	MaxObjects int
+	// Timeout is the wall-clock time of evaluation
+	Timeout time.Duration
+}
+
+func WithLimits(limits Limits) Option {
+	return func(v *VM) {
+		v.limits = limits
+	}
+}
+
+// EvalContext evaluates parsed form like EvalForm and aborts the evaluation
+// when ctx is done
+func (v *VM) EvalContext(ctx context.Context, form Object) (Object, error) {
+	var result Object
+	err := v.limited(ctx, func() {
+		result = v.eval(form)
+	})
+	return result, err
+}
+
+// limited runs f inside Try with the limits of VM. Nested evaluations share
+// the limits of the outermost one.
+func (v *VM) limited(ctx context.Context, f func()) error {
+	if v.evaluating {
+		return v.Try(f)
+	}
+
+	if v.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.limits.Timeout)
+		defer cancel()
+	}
+
+	v.evaluating = true
+	v.ctx = ctx
+	v.steps = 0
+	v.depth = 0
+	defer func() {
+		v.evaluating = false
+		v.ctx = nil
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return v.Try(f)
+}
+
+// step counts a function call and aborts evaluation which ran out of steps
+// or whose context is done. From time to time it lets other tasks run.
+func (v *VM) step() {
+	if !v.evaluating {
+		return
+	}
+
+	v.steps++
+	if v.limits.MaxSteps > 0 && v.steps > v.limits.MaxSteps {
+		Error(ErrStepLimit)
+	}
+
+	if v.steps%contextCheckInterval == 0 {
+		v.checkContext()
+	}
+	if v.steps%yieldInterval == 0 {
+		v.yield()
+	}
+}
+
+// enter counts a nested call, leave has to be called when it returns
+func (v *VM) enter() {
+	v.depth++
+	if v.evaluating && v.limits.MaxDepth > 0 && v.depth > v.limits.MaxDepth {
+		Error(ErrDepthLimit)
+	}
+}
+
+func (v *VM) leave() {
+	v.depth--
+}
+
+// checkHeapLimit aborts evaluation when the heap holds too many objects, it
+// is called after a full collection
+func (v *VM) checkHeapLimit() {
+	if v.overHeapLimit() {
+		Error(ErrHeapLimit)
+	}
+}
+
+func (v *VM) overHeapLimit() bool {
+	return v.evaluating && v.limits.MaxObjects > 0 && v.heap.Objects() >= v.limits.MaxObjects
+}
@@ -0,0 +1,61 @@
+package runtime
+
+// markSweep collects the whole heap once the number of objects doubles
+type markSweep struct {
+	threshold int
+}
+
+func newMarkSweep() *markSweep {
+	return &markSweep{
+		threshold: 10,
+	}
+}
+
+func (m *markSweep) shouldCollect(v *VM) bool {
+	return v.heap.Objects() >= m.threshold
+}
+
+func (m *markSweep) collect(v *VM, full bool) (bool, bool) {
+	v.mark()
+	v.queueFinalizers(isMarked, v.markFrom)
+	v.sweep()
+
+	m.threshold = v.heap.Objects() * 2
+
+	return true, true
+}
+
+func (m *markSweep) allocated(v *VM, o Object, blockIndex int) {}
+
+func (m *markSweep) writeBarrier(v *VM, parent, child Object) {}
+
+func (m *markSweep) gcThreshold() int {
+	return m.threshold
+}
+
+func (v *VM) mark() {
+	gray := make([]Object, 0)
+
+	v.roots(func(o Object) {
+		if !o.IsMarked() {
+			o.Mark()
+			gray = append(gray, o)
+		}
+	})
+
+	trace(gray, traceAll)
+}
+
+func (v *VM) sweep() {
+	for i, o := range v.heap.memory {
+		if o == nil {
+			continue
+		}
+
+		if o.IsMarked() {
+			o.UnMark()
+		} else {
+			v.FreeObject(i)
+		}
+	}
+}
@@ -20,8 +20,56 @@ const (
 	TypeSyntax
 	TypeSymbol
 	TypeBool
+	TypeString
+	TypeWeakBox
+	TypeHashTable
+	TypeEnvironment
+	TypeClosure
+	TypeCode
+	TypeChannel
+	TypeCoroutine
+	TypeContinuation
 )
 
+var typeNames = map[ObjectType]string{
+	TypeNil:          "nil",
+	TypeVoid:         "void",
+	TypeInteger:      "integer",
+	TypeCons:         "cons",
+	TypeFunction:     "function",
+	TypeSyntax:       "syntax",
+	TypeSymbol:       "symbol",
+	TypeBool:         "bool",
+	TypeString:       "string",
+	TypeWeakBox:      "weak-box",
+	TypeHashTable:    "hash-table",
+	TypeEnvironment:  "environment",
+	TypeClosure:      "closure",
+	TypeCode:         "code",
+	TypeChannel:      "channel",
+	TypeCoroutine:    "coroutine",
+	TypeContinuation: "continuation",
+}
+
+// isFunction reports whether arguments are evaluated before o is called
+func isFunction(o Object) bool {
+	return o.Type() == TypeFunction || o.Type() == TypeClosure || o.Type() == TypeContinuation
+}
+
+// isImmortal reports whether object lives outside of the heap and is never
+// collected, these are integers passed by value and singletons of VM
+func isImmortal(o Object) bool {
+	switch o.Type() {
+	case TypeInteger, TypeNil, TypeVoid, TypeBool:
+		return true
+	}
+	return false
+}
+
+func (t ObjectType) String() string {
+	return typeNames[t]
+}
+
 type Object interface {
 	Allocate(*VM) Object
 	Evaluate() Object
@@ -36,22 +84,19 @@ type Object interface {
 	Mark()
 	UnMark()
 	IsMarked() bool
+	References() []Object
 }
 
-// Nil Object
-type NilObject struct {
-	marked bool
-}
+// NilObject is an immortal singleton of VM, it is not stored in the heap
+type NilObject struct{}
 
 func NewNilObject() Object {
-	return &NilObject{
-		marked: false,
-	}
+	return &NilObject{}
 }
 
+// Allocate returns the nil of VM
 func (n *NilObject) Allocate(vm *VM) Object {
-	vm.AllocateObject(n)
-	return n
+	return vm.nilObject
 }
 
 func (n *NilObject) Evaluate() Object {
@@ -96,32 +141,29 @@ func (n *NilObject) Type() ObjectType {
 	return TypeNil
 }
 
-func (n *NilObject) Mark() {
-	n.marked = true
-}
+// immortal objects are always marked, so collectors never trace them
+func (n *NilObject) Mark() {}
 
-func (n *NilObject) UnMark() {
-	n.marked = false
-}
+func (n *NilObject) UnMark() {}
 
 func (n *NilObject) IsMarked() bool {
-	return n.marked
+	return true
 }
 
-// Void Object
-type VoidObject struct {
-	marked bool
+func (n *NilObject) References() []Object {
+	return nil
 }
 
+// VoidObject is an immortal singleton of VM, it is not stored in the heap
+type VoidObject struct{}
+
 func NewVoidObject() Object {
-	return &VoidObject{
-		marked: false,
-	}
+	return &VoidObject{}
 }
 
+// Allocate returns the void of VM
 func (v *VoidObject) Allocate(vm *VM) Object {
-	vm.AllocateObject(v)
-	return v
+	return vm.voidObject
 }
 
 func (v *VoidObject) Evaluate() Object {
@@ -166,87 +208,87 @@ func (v *VoidObject) Type() ObjectType {
 	return TypeVoid
 }
 
-func (v *VoidObject) Mark() {
-	v.marked = true
-}
+// immortal objects are always marked, so collectors never trace them
+func (v *VoidObject) Mark() {}
 
-func (v *VoidObject) UnMark() {
-	v.marked = false
-}
+func (v *VoidObject) UnMark() {}
 
 func (v *VoidObject) IsMarked() bool {
-	return v.marked
+	return true
+}
+
+func (v *VoidObject) References() []Object {
+	return nil
 }
 
-// Integer Object
+// Integer Object is an immediate value. It is passed around by value, does
+// not occupy a heap slot and is never collected.
 type IntegerObject struct {
-	value  int
-	marked bool
+	value int
 }
 
 func NewIntegerObject(v int) Object {
-	return &IntegerObject{
-		value:  v,
-		marked: false,
+	return IntegerObject{
+		value: v,
 	}
 }
 
-func (i *IntegerObject) Allocate(vm *VM) Object {
-	vm.AllocateObject(i)
+func (i IntegerObject) Allocate(vm *VM) Object {
 	return i
 }
 
-func (i *IntegerObject) Evaluate() Object {
+func (i IntegerObject) Evaluate() Object {
 	return i
 }
 
-func (i *IntegerObject) EvaluateFunction(args int) Object {
+func (i IntegerObject) EvaluateFunction(args int) Object {
 	Error(errors.New("IntegerObject does not have EvaluateFunction"))
 	return nil
 }
 
-func (i *IntegerObject) Car() Object {
+func (i IntegerObject) Car() Object {
 	Error(errors.New("IntegerObject does not have Car"))
 	return i
 }
 
-func (i *IntegerObject) Cdr() Object {
+func (i IntegerObject) Cdr() Object {
 	Error(errors.New("IntegerObject does not have Cdr"))
 	return i
 }
 
-func (i *IntegerObject) IntegerValue() int {
+func (i IntegerObject) IntegerValue() int {
 	return i.value
 }
 
-func (i *IntegerObject) StringValue() string {
+func (i IntegerObject) StringValue() string {
 	Error(errors.New("IntegerObject does not have StringValue"))
 	return ""
 }
 
-func (i *IntegerObject) BoolValue() bool {
+func (i IntegerObject) BoolValue() bool {
 	Error(errors.New("IntegerObject does not have BoolValue"))
 	return false
 }
 
-func (i *IntegerObject) String() string {
+func (i IntegerObject) String() string {
 	return strconv.Itoa(i.value)
 }
 
-func (i *IntegerObject) Type() ObjectType {
+func (i IntegerObject) Type() ObjectType {
 	return TypeInteger
 }
 
-func (i *IntegerObject) Mark() {
-	i.marked = true
-}
+// immortal objects are always marked, so collectors never trace them
+func (i IntegerObject) Mark() {}
+
+func (i IntegerObject) UnMark() {}
 
-func (i *IntegerObject) UnMark() {
-	i.marked = false
+func (i IntegerObject) IsMarked() bool {
+	return true
 }
 
-func (i *IntegerObject) IsMarked() bool {
-	return i.marked
+func (i IntegerObject) References() []Object {
+	return nil
 }
 
 // Cons Object
@@ -277,9 +319,16 @@ func (c *ConsObject) Allocate(vm *VM) Object {
 }
 
 func (c *ConsObject) Evaluate() Object {
-	function := c.car.Evaluate()
+	c.vm.checkLive(c)
+
+	scope := c.vm.OpenScope()
+	defer scope.Close()
+	scope.Root(c)
 
-	if function.Type() != TypeFunction && function.Type() != TypeSyntax {
+	function := scope.Root(c.car.Evaluate())
+	c.vm.checkLive(function)
+
+	if !isFunction(function) && function.Type() != TypeSyntax {
 		return c
 	}
 
@@ -291,9 +340,10 @@ func (c *ConsObject) Evaluate() Object {
 		}
 
 		o := args.Car()
-		if function.Type() == TypeFunction {
+		if isFunction(function) {
 			o = o.Evaluate()
 		}
+		c.vm.checkLive(o)
 
 		c.vm.Stack().Push(o)
 		numArgs++
@@ -301,6 +351,7 @@ func (c *ConsObject) Evaluate() Object {
 		args = args.Cdr()
 	}
 
+	c.vm.step()
 	return function.EvaluateFunction(numArgs)
 }
 
@@ -362,6 +413,10 @@ func (c *ConsObject) IsMarked() bool {
 	return c.marked
 }
 
+func (c *ConsObject) References() []Object {
+	return []Object{c.car, c.cdr}
+}
+
 // Function Object
 
 type FunctionObject struct {
@@ -383,13 +438,14 @@ func NewFunctionObject(name string, f func(int, *VM) Object) Object {
 func (f *FunctionObject) Allocate(vm *VM) Object {
 	vm.AllocateObject(f)
 	f.vm = vm
-	f.vm.variables[f.name] = f
+	f.vm.global(f.name).value = f
 	return f
 }
 
+// Evaluate returns the function itself, optimizer puts builtins directly
+// into parsed forms
 func (f *FunctionObject) Evaluate() Object {
-	Error(errors.New("FunctionObject does not have Evaluate"))
-	return nil
+	return f
 }
 
 func (f *FunctionObject) EvaluateFunction(args int) Object {
@@ -441,6 +497,10 @@ func (f *FunctionObject) IsMarked() bool {
 	return f.marked
 }
 
+func (f *FunctionObject) References() []Object {
+	return nil
+}
+
 // Syntax Object
 
 type SyntaxObject struct {
@@ -462,7 +522,7 @@ func NewSyntaxObject(name string, f func(int, *VM) Object) Object {
 func (s *SyntaxObject) Allocate(vm *VM) Object {
 	vm.AllocateObject(s)
 	s.vm = vm
-	s.vm.variables[s.name] = s
+	s.vm.global(s.name).value = s
 	return s
 }
 
@@ -520,11 +580,23 @@ func (s *SyntaxObject) IsMarked() bool {
 	return s.marked
 }
 
+func (s *SyntaxObject) References() []Object {
+	return nil
+}
+
 // Symbol Object
 
 type SymbolObject struct {
-	name   string
-	vm     *VM
+	name string
+	vm   *VM
+
+	// lexical address set by VM.Resolve, local variables are found by
+	// depth in the environment chain and index, global variables by cell
+	local  bool
+	depth  int
+	index  int
+	global *Global
+
 	marked bool
 }
 
@@ -543,8 +615,26 @@ func (s *SymbolObject) Allocate(vm *VM) Object {
 }
 
 func (s *SymbolObject) Evaluate() Object {
-	o, found := s.vm.variables[s.name]
-	if !found {
+	s.vm.checkLive(s)
+
+	if s.local {
+		if s.vm.env == nil {
+			Error(errors.Errorf("variable %s is not in scope", s.name))
+			return NewVoidObject().Allocate(s.vm)
+		}
+		return s.vm.env.At(s.depth, s.index)
+	}
+
+	if s.global == nil {
+		// symbol was not resolved, look it up by name
+		if o, found := s.vm.env.Lookup(s.name); found {
+			return o
+		}
+		s.global = s.vm.global(s.name)
+	}
+
+	o := s.global.value
+	if o == nil {
 		Error(errors.Errorf("variable %s not found", s.name))
 		return NewVoidObject().Allocate(s.vm)
 	}
@@ -581,7 +671,7 @@ func (s *SymbolObject) BoolValue() bool {
 }
 
 func (s *SymbolObject) String() string {
-	return "symbol"
+	return s.name
 }
 
 func (s *SymbolObject) Type() ObjectType {
@@ -600,23 +690,30 @@ func (s *SymbolObject) IsMarked() bool {
 	return s.marked
 }
 
+func (s *SymbolObject) References() []Object {
+	return nil
+}
+
 // Bool Object
 
+// BoolObject values are immortal singletons of VM, they are not stored in the
+// heap, so bools can be compared by identity
 type BoolObject struct {
-	value  bool
-	marked bool
+	value bool
 }
 
 func NewBoolObject(value bool) Object {
 	return &BoolObject{
-		value:  value,
-		marked: false,
+		value: value,
 	}
 }
 
+// Allocate returns the true or false of VM
 func (b *BoolObject) Allocate(vm *VM) Object {
-	vm.AllocateObject(b)
-	return b
+	if b.value {
+		return vm.trueObject
+	}
+	return vm.falseObject
 }
 
 func (b *BoolObject) Evaluate() Object {
@@ -663,14 +760,1042 @@ func (b *BoolObject) Type() ObjectType {
 	return TypeBool
 }
 
-func (b *BoolObject) Mark() {
-	b.marked = true
+// immortal objects are always marked, so collectors never trace them
+func (b *BoolObject) Mark() {}
+
+func (b *BoolObject) UnMark() {}
+
+func (b *BoolObject) IsMarked() bool {
+	return true
+}
+
+func (b *BoolObject) References() []Object {
+	return nil
 }
 
-func (b *BoolObject) UnMark() {
-	b.marked = false
+// String Object
+
+type StringObject struct {
+	value  string
+	marked bool
 }
 
-func (b *BoolObject) IsMarked() bool {
-	return b.marked
+func NewStringObject(value string) Object {
+	return &StringObject{
+		value:  value,
+		marked: false,
+	}
+}
+
+func (s *StringObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(s)
+	return s
+}
+
+func (s *StringObject) Evaluate() Object {
+	return s
+}
+
+func (s *StringObject) EvaluateFunction(args int) Object {
+	Error(errors.New("StringObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (s *StringObject) Car() Object {
+	Error(errors.New("StringObject does not have Car"))
+	return nil
+}
+
+func (s *StringObject) Cdr() Object {
+	Error(errors.New("StringObject does not have Cdr"))
+	return nil
+}
+
+func (s *StringObject) IntegerValue() int {
+	Error(errors.New("StringObject does not have IntegerValue"))
+	return 0
+}
+
+func (s *StringObject) StringValue() string {
+	return s.value
+}
+
+func (s *StringObject) BoolValue() bool {
+	Error(errors.New("StringObject does not have BoolValue"))
+	return false
+}
+
+func (s *StringObject) String() string {
+	return strconv.Quote(s.value)
+}
+
+func (s *StringObject) Type() ObjectType {
+	return TypeString
+}
+
+func (s *StringObject) Mark() {
+	s.marked = true
+}
+
+func (s *StringObject) UnMark() {
+	s.marked = false
+}
+
+func (s *StringObject) IsMarked() bool {
+	return s.marked
+}
+
+func (s *StringObject) References() []Object {
+	return nil
+}
+
+// Weak Box Object
+
+type WeakBoxObject struct {
+	// value is nil once the object was collected
+	value  Object
+	marked bool
+}
+
+func NewWeakBoxObject(value Object) Object {
+	return &WeakBoxObject{
+		value:  value,
+		marked: false,
+	}
+}
+
+func (w *WeakBoxObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(w)
+	vm.weakBoxes[w] = true
+	return w
+}
+
+func (w *WeakBoxObject) Evaluate() Object {
+	return w
+}
+
+func (w *WeakBoxObject) EvaluateFunction(args int) Object {
+	Error(errors.New("WeakBoxObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (w *WeakBoxObject) Car() Object {
+	Error(errors.New("WeakBoxObject does not have Car"))
+	return nil
+}
+
+func (w *WeakBoxObject) Cdr() Object {
+	Error(errors.New("WeakBoxObject does not have Cdr"))
+	return nil
+}
+
+func (w *WeakBoxObject) IntegerValue() int {
+	Error(errors.New("WeakBoxObject does not have IntegerValue"))
+	return 0
+}
+
+func (w *WeakBoxObject) StringValue() string {
+	Error(errors.New("WeakBoxObject does not have StringValue"))
+	return ""
+}
+
+func (w *WeakBoxObject) BoolValue() bool {
+	Error(errors.New("WeakBoxObject does not have BoolValue"))
+	return false
+}
+
+func (w *WeakBoxObject) String() string {
+	return "weak-box"
+}
+
+func (w *WeakBoxObject) Type() ObjectType {
+	return TypeWeakBox
+}
+
+func (w *WeakBoxObject) Mark() {
+	w.marked = true
+}
+
+func (w *WeakBoxObject) UnMark() {
+	w.marked = false
+}
+
+func (w *WeakBoxObject) IsMarked() bool {
+	return w.marked
+}
+
+// References does not return the value, weak box does not keep it alive
+func (w *WeakBoxObject) References() []Object {
+	return nil
+}
+
+// Value returns the boxed object or nil if it was collected
+func (w *WeakBoxObject) Value() Object {
+	return w.value
+}
+
+// Hash Table Object
+
+type hashEntry struct {
+	key   Object
+	value Object
+}
+
+type HashTableObject struct {
+	entries map[interface{}]hashEntry
+	// weak tables do not keep keys alive, entries are removed once keys are
+	// collected
+	weak   bool
+	marked bool
+}
+
+func NewHashTableObject(weak bool) Object {
+	return &HashTableObject{
+		entries: make(map[interface{}]hashEntry),
+		weak:    weak,
+		marked:  false,
+	}
+}
+
+// hashKey compares integers, strings, symbols and bools by value and other
+// objects by identity
+func hashKey(o Object) interface{} {
+	type valueKey struct {
+		t ObjectType
+		v interface{}
+	}
+
+	switch o.Type() {
+	case TypeInteger:
+		return valueKey{o.Type(), o.IntegerValue()}
+	case TypeString, TypeSymbol:
+		return valueKey{o.Type(), o.StringValue()}
+	case TypeBool:
+		return valueKey{o.Type(), o.BoolValue()}
+	default:
+		return o
+	}
+}
+
+func (h *HashTableObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(h)
+	if h.weak {
+		vm.weakTables[h] = true
+	}
+	return h
+}
+
+func (h *HashTableObject) Evaluate() Object {
+	return h
+}
+
+func (h *HashTableObject) EvaluateFunction(args int) Object {
+	Error(errors.New("HashTableObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (h *HashTableObject) Car() Object {
+	Error(errors.New("HashTableObject does not have Car"))
+	return nil
+}
+
+func (h *HashTableObject) Cdr() Object {
+	Error(errors.New("HashTableObject does not have Cdr"))
+	return nil
+}
+
+func (h *HashTableObject) IntegerValue() int {
+	Error(errors.New("HashTableObject does not have IntegerValue"))
+	return 0
+}
+
+func (h *HashTableObject) StringValue() string {
+	Error(errors.New("HashTableObject does not have StringValue"))
+	return ""
+}
+
+func (h *HashTableObject) BoolValue() bool {
+	Error(errors.New("HashTableObject does not have BoolValue"))
+	return false
+}
+
+func (h *HashTableObject) String() string {
+	return "hash-table"
+}
+
+func (h *HashTableObject) Type() ObjectType {
+	return TypeHashTable
+}
+
+func (h *HashTableObject) Mark() {
+	h.marked = true
+}
+
+func (h *HashTableObject) UnMark() {
+	h.marked = false
+}
+
+func (h *HashTableObject) IsMarked() bool {
+	return h.marked
+}
+
+func (h *HashTableObject) References() []Object {
+	refs := make([]Object, 0, 2*len(h.entries))
+	for _, entry := range h.entries {
+		if !h.weak {
+			refs = append(refs, entry.key)
+		}
+		refs = append(refs, entry.value)
+	}
+	return refs
+}
+
+func (h *HashTableObject) Get(key Object) (Object, bool) {
+	entry, found := h.entries[hashKey(key)]
+	return entry.value, found
+}
+
+func (h *HashTableObject) Set(vm *VM, key, value Object) {
+	h.entries[hashKey(key)] = hashEntry{key: key, value: value}
+	vm.WriteBarrier(h, key)
+	vm.WriteBarrier(h, value)
+}
+
+func (h *HashTableObject) Remove(key Object) {
+	delete(h.entries, hashKey(key))
+}
+
+func (h *HashTableObject) Len() int {
+	return len(h.entries)
+}
+
+// Each calls f for every entry of the table
+func (h *HashTableObject) Each(f func(key, value Object)) {
+	for _, entry := range h.entries {
+		f(entry.key, entry.value)
+	}
+}
+
+// Environment Object
+
+// EnvironmentObject holds values of lambda parameters, variables which are not
+// found in the chain of environments are global
+type EnvironmentObject struct {
+	names  []string
+	values []Object
+	parent *EnvironmentObject
+	marked bool
+}
+
+func NewEnvironmentObject(names []string, values []Object, parent *EnvironmentObject) Object {
+	return &EnvironmentObject{
+		names:  names,
+		values: values,
+		parent: parent,
+		marked: false,
+	}
+}
+
+func (e *EnvironmentObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(e)
+	return e
+}
+
+// At returns value of variable resolved to lexical address depth and index
+func (e *EnvironmentObject) At(depth, index int) Object {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.parent
+	}
+	return env.values[index]
+}
+
+// Lookup finds value of variable in the chain of environments
+func (e *EnvironmentObject) Lookup(name string) (Object, bool) {
+	for env := e; env != nil; env = env.parent {
+		for i, n := range env.names {
+			if n == name {
+				return env.values[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (e *EnvironmentObject) Evaluate() Object {
+	Error(errors.New("EnvironmentObject does not have Evaluate"))
+	return nil
+}
+
+func (e *EnvironmentObject) EvaluateFunction(args int) Object {
+	Error(errors.New("EnvironmentObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (e *EnvironmentObject) Car() Object {
+	Error(errors.New("EnvironmentObject does not have Car"))
+	return nil
+}
+
+func (e *EnvironmentObject) Cdr() Object {
+	Error(errors.New("EnvironmentObject does not have Cdr"))
+	return nil
+}
+
+func (e *EnvironmentObject) IntegerValue() int {
+	Error(errors.New("EnvironmentObject does not have IntegerValue"))
+	return 0
+}
+
+func (e *EnvironmentObject) StringValue() string {
+	Error(errors.New("EnvironmentObject does not have StringValue"))
+	return ""
+}
+
+func (e *EnvironmentObject) BoolValue() bool {
+	Error(errors.New("EnvironmentObject does not have BoolValue"))
+	return false
+}
+
+func (e *EnvironmentObject) String() string {
+	return "environment"
+}
+
+func (e *EnvironmentObject) Type() ObjectType {
+	return TypeEnvironment
+}
+
+func (e *EnvironmentObject) Mark() {
+	e.marked = true
+}
+
+func (e *EnvironmentObject) UnMark() {
+	e.marked = false
+}
+
+func (e *EnvironmentObject) IsMarked() bool {
+	return e.marked
+}
+
+func (e *EnvironmentObject) References() []Object {
+	refs := make([]Object, 0, len(e.values)+1)
+	refs = append(refs, e.values...)
+	if e.parent != nil {
+		refs = append(refs, e.parent)
+	}
+	return refs
+}
+
+// Lambda Object
+
+// LambdaObject is a function defined in Lisp and evaluated by tree walking
+type LambdaObject struct {
+	params []string
+	body   []Object
+	env    *EnvironmentObject
+	vm     *VM
+	marked bool
+}
+
+func NewLambdaObject(params []string, body []Object, env *EnvironmentObject) Object {
+	return &LambdaObject{
+		params: params,
+		body:   body,
+		env:    env,
+		vm:     nil,
+		marked: false,
+	}
+}
+
+func (l *LambdaObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(l)
+	l.vm = vm
+	return l
+}
+
+func (l *LambdaObject) Evaluate() Object {
+	Error(errors.New("LambdaObject does not have Evaluate"))
+	return nil
+}
+
+func (l *LambdaObject) EvaluateFunction(args int) Object {
+	if args != len(l.params) {
+		Error(errors.Errorf("lambda expects %d arguments", len(l.params)))
+		l.vm.Stack().PopTimes(args)
+		return NewVoidObject().Allocate(l.vm)
+	}
+
+	values := make([]Object, args)
+	for i := args - 1; i >= 0; i-- {
+		values[i] = l.vm.Stack().Pop()
+	}
+
+	l.vm.enter()
+	defer l.vm.leave()
+
+	scope := l.vm.OpenScope()
+	defer scope.Close()
+	scope.Root(l)
+
+	saved := l.vm.env
+	if saved != nil {
+		scope.Root(saved)
+	}
+
+	l.vm.env = NewEnvironmentObject(l.params, values, l.env).Allocate(l.vm).(*EnvironmentObject)
+	defer func() {
+		l.vm.env = saved
+	}()
+
+	var result Object = NewVoidObject().Allocate(l.vm)
+	for _, form := range l.body {
+		result = form.Evaluate()
+	}
+
+	return result
+}
+
+func (l *LambdaObject) Car() Object {
+	Error(errors.New("LambdaObject does not have Car"))
+	return nil
+}
+
+func (l *LambdaObject) Cdr() Object {
+	Error(errors.New("LambdaObject does not have Cdr"))
+	return nil
+}
+
+func (l *LambdaObject) IntegerValue() int {
+	Error(errors.New("LambdaObject does not have IntegerValue"))
+	return 0
+}
+
+func (l *LambdaObject) StringValue() string {
+	Error(errors.New("LambdaObject does not have StringValue"))
+	return ""
+}
+
+func (l *LambdaObject) BoolValue() bool {
+	Error(errors.New("LambdaObject does not have BoolValue"))
+	return false
+}
+
+func (l *LambdaObject) String() string {
+	return "lambda"
+}
+
+func (l *LambdaObject) Type() ObjectType {
+	return TypeClosure
+}
+
+func (l *LambdaObject) Mark() {
+	l.marked = true
+}
+
+func (l *LambdaObject) UnMark() {
+	l.marked = false
+}
+
+func (l *LambdaObject) IsMarked() bool {
+	return l.marked
+}
+
+func (l *LambdaObject) References() []Object {
+	refs := make([]Object, 0, len(l.body)+1)
+	refs = append(refs, l.body...)
+	if l.env != nil {
+		refs = append(refs, l.env)
+	}
+	return refs
+}
+
+// Code Object
+
+// CodeObject is a compiled function
+type CodeObject struct {
+	name         string
+	params       []string
+	instructions []Instruction
+	// positions holds source position of every instruction
+	positions []Position
+	constants []Object
+	// globals holds cells of global variables the code refers to
+	globals []*Global
+	// captured holds names of captured variables of enclosing functions
+	captured []string
+	marked   bool
+}
+
+func NewCodeObject(name string, params []string, instructions []Instruction, positions []Position, constants []Object, globals []*Global, captured []string) Object {
+	return &CodeObject{
+		name:         name,
+		params:       params,
+		instructions: instructions,
+		positions:    positions,
+		globals:      globals,
+		constants:    constants,
+		captured:     captured,
+		marked:       false,
+	}
+}
+
+func (c *CodeObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(c)
+	return c
+}
+
+func (c *CodeObject) Evaluate() Object {
+	Error(errors.New("CodeObject does not have Evaluate"))
+	return nil
+}
+
+func (c *CodeObject) EvaluateFunction(args int) Object {
+	Error(errors.New("CodeObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (c *CodeObject) Car() Object {
+	Error(errors.New("CodeObject does not have Car"))
+	return nil
+}
+
+func (c *CodeObject) Cdr() Object {
+	Error(errors.New("CodeObject does not have Cdr"))
+	return nil
+}
+
+func (c *CodeObject) IntegerValue() int {
+	Error(errors.New("CodeObject does not have IntegerValue"))
+	return 0
+}
+
+func (c *CodeObject) StringValue() string {
+	Error(errors.New("CodeObject does not have StringValue"))
+	return ""
+}
+
+func (c *CodeObject) BoolValue() bool {
+	Error(errors.New("CodeObject does not have BoolValue"))
+	return false
+}
+
+func (c *CodeObject) String() string {
+	return "code"
+}
+
+func (c *CodeObject) Type() ObjectType {
+	return TypeCode
+}
+
+func (c *CodeObject) Mark() {
+	c.marked = true
+}
+
+func (c *CodeObject) UnMark() {
+	c.marked = false
+}
+
+func (c *CodeObject) IsMarked() bool {
+	return c.marked
+}
+
+func (c *CodeObject) References() []Object {
+	return c.constants
+}
+
+// Closure Object
+
+// ClosureObject is a compiled function together with values of variables it
+// captured. Variables can not be assigned, so they are captured by value.
+type ClosureObject struct {
+	code     *CodeObject
+	captured []Object
+	vm       *VM
+	marked   bool
+}
+
+func NewClosureObject(code *CodeObject, captured []Object) Object {
+	return &ClosureObject{
+		code:     code,
+		captured: captured,
+		vm:       nil,
+		marked:   false,
+	}
+}
+
+func (c *ClosureObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(c)
+	c.vm = vm
+	return c
+}
+
+func (c *ClosureObject) Evaluate() Object {
+	Error(errors.New("ClosureObject does not have Evaluate"))
+	return nil
+}
+
+func (c *ClosureObject) EvaluateFunction(args int) Object {
+	return c.vm.run(c, args)
+}
+
+func (c *ClosureObject) Car() Object {
+	Error(errors.New("ClosureObject does not have Car"))
+	return nil
+}
+
+func (c *ClosureObject) Cdr() Object {
+	Error(errors.New("ClosureObject does not have Cdr"))
+	return nil
+}
+
+func (c *ClosureObject) IntegerValue() int {
+	Error(errors.New("ClosureObject does not have IntegerValue"))
+	return 0
+}
+
+func (c *ClosureObject) StringValue() string {
+	Error(errors.New("ClosureObject does not have StringValue"))
+	return ""
+}
+
+func (c *ClosureObject) BoolValue() bool {
+	Error(errors.New("ClosureObject does not have BoolValue"))
+	return false
+}
+
+func (c *ClosureObject) String() string {
+	return "closure"
+}
+
+func (c *ClosureObject) Type() ObjectType {
+	return TypeClosure
+}
+
+func (c *ClosureObject) Mark() {
+	c.marked = true
+}
+
+func (c *ClosureObject) UnMark() {
+	c.marked = false
+}
+
+func (c *ClosureObject) IsMarked() bool {
+	return c.marked
+}
+
+func (c *ClosureObject) References() []Object {
+	refs := make([]Object, 0, len(c.captured)+1)
+	refs = append(refs, c.code)
+	refs = append(refs, c.captured...)
+	return refs
+}
+
+// Channel Object
+
+// ChannelObject passes values between tasks. Values waiting in the buffer are
+// references of the channel, so GC keeps them alive. Channel with capacity 0
+// accepts a value only when a receiver is waiting.
+type ChannelObject struct {
+	buffer   []Object
+	capacity int
+	closed   bool
+	// receivers is the number of tasks waiting to receive
+	receivers int
+	marked    bool
+}
+
+func NewChannelObject(capacity int) Object {
+	return &ChannelObject{
+		buffer:    make([]Object, 0),
+		capacity:  capacity,
+		closed:    false,
+		receivers: 0,
+		marked:    false,
+	}
+}
+
+func (c *ChannelObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(c)
+	return c
+}
+
+func (c *ChannelObject) Evaluate() Object {
+	return c
+}
+
+func (c *ChannelObject) EvaluateFunction(args int) Object {
+	Error(errors.New("ChannelObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (c *ChannelObject) Car() Object {
+	Error(errors.New("ChannelObject does not have Car"))
+	return nil
+}
+
+func (c *ChannelObject) Cdr() Object {
+	Error(errors.New("ChannelObject does not have Cdr"))
+	return nil
+}
+
+func (c *ChannelObject) IntegerValue() int {
+	Error(errors.New("ChannelObject does not have IntegerValue"))
+	return 0
+}
+
+func (c *ChannelObject) StringValue() string {
+	Error(errors.New("ChannelObject does not have StringValue"))
+	return ""
+}
+
+func (c *ChannelObject) BoolValue() bool {
+	Error(errors.New("ChannelObject does not have BoolValue"))
+	return false
+}
+
+func (c *ChannelObject) String() string {
+	return "channel"
+}
+
+func (c *ChannelObject) Type() ObjectType {
+	return TypeChannel
+}
+
+func (c *ChannelObject) Mark() {
+	c.marked = true
+}
+
+func (c *ChannelObject) UnMark() {
+	c.marked = false
+}
+
+func (c *ChannelObject) IsMarked() bool {
+	return c.marked
+}
+
+func (c *ChannelObject) References() []Object {
+	return c.buffer
+}
+
+// Coroutine Object
+
+// CoroutineObject runs function in its own goroutine, which takes turns with
+// the goroutine resuming it. Suspended coroutine keeps its stack, handles and
+// environment in its task, they are references of the coroutine.
+type CoroutineObject struct {
+	function Object
+	task     *task
+	state    coroutineState
+	// value is passed by resume to yield and back
+	value Object
+	err   error
+	// in resumes the coroutine or stops it when false, out hands control back
+	in     chan bool
+	out    chan struct{}
+	vm     *VM
+	marked bool
+}
+
+func NewCoroutineObject(function Object) Object {
+	return &CoroutineObject{
+		function: function,
+		task:     nil,
+		state:    coroutineNew,
+		value:    nil,
+		err:      nil,
+		in:       make(chan bool),
+		out:      make(chan struct{}),
+		vm:       nil,
+		marked:   false,
+	}
+}
+
+func (c *CoroutineObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(c)
+	c.vm = vm
+	return c
+}
+
+func (c *CoroutineObject) Evaluate() Object {
+	return c
+}
+
+func (c *CoroutineObject) EvaluateFunction(args int) Object {
+	Error(errors.New("CoroutineObject does not have EvaluateFunction"))
+	return nil
+}
+
+func (c *CoroutineObject) Car() Object {
+	Error(errors.New("CoroutineObject does not have Car"))
+	return nil
+}
+
+func (c *CoroutineObject) Cdr() Object {
+	Error(errors.New("CoroutineObject does not have Cdr"))
+	return nil
+}
+
+func (c *CoroutineObject) IntegerValue() int {
+	Error(errors.New("CoroutineObject does not have IntegerValue"))
+	return 0
+}
+
+func (c *CoroutineObject) StringValue() string {
+	Error(errors.New("CoroutineObject does not have StringValue"))
+	return ""
+}
+
+func (c *CoroutineObject) BoolValue() bool {
+	Error(errors.New("CoroutineObject does not have BoolValue"))
+	return false
+}
+
+func (c *CoroutineObject) String() string {
+	return "coroutine"
+}
+
+func (c *CoroutineObject) Type() ObjectType {
+	return TypeCoroutine
+}
+
+func (c *CoroutineObject) Mark() {
+	c.marked = true
+}
+
+func (c *CoroutineObject) UnMark() {
+	c.marked = false
+}
+
+func (c *CoroutineObject) IsMarked() bool {
+	return c.marked
+}
+
+// References returns state of the coroutine task unless the task is running,
+// then its state is in VM
+func (c *CoroutineObject) References() []Object {
+	refs := []Object{c.function}
+	if c.value != nil {
+		refs = append(refs, c.value)
+	}
+	if c.task == nil || c.vm.current == c.task {
+		return refs
+	}
+
+	c.task.references(func(_ string, _ int, o Object) {
+		refs = append(refs, o)
+	})
+	return refs
+}
+
+// Continuation Object
+
+// ContinuationObject returns from call/cc which created it. It is an escaping
+// continuation, it can be called only by the task which created it while the
+// call/cc is running.
+type ContinuationObject struct {
+	task *task
+	// finalizer is set for continuations created by finalizers, which must
+	// not escape from finalizers and finalizers must not escape from code
+	// running when they were called
+	finalizer bool
+	active    bool
+	// value is passed to call/cc while the Go stack unwinds
+	value  Object
+	vm     *VM
+	marked bool
+}
+
+func NewContinuationObject(t *task, finalizer bool) Object {
+	return &ContinuationObject{
+		task:      t,
+		finalizer: finalizer,
+		active:    false,
+		value:     nil,
+		vm:        nil,
+		marked:    false,
+	}
+}
+
+func (k *ContinuationObject) Allocate(vm *VM) Object {
+	vm.AllocateObject(k)
+	k.vm = vm
+	return k
+}
+
+func (k *ContinuationObject) Evaluate() Object {
+	return k
+}
+
+// EvaluateFunction escapes to call/cc which created the continuation, which
+// then returns the argument
+func (k *ContinuationObject) EvaluateFunction(args int) Object {
+	if args != 1 {
+		Error(errors.New("continuation expects 1 argument"))
+		k.vm.Stack().PopTimes(args)
+		return NewVoidObject().Allocate(k.vm)
+	}
+
+	value := k.vm.Stack().Pop()
+	if !k.active {
+		Error(errors.New("continuation can not be called after call/cc returned"))
+	}
+	if k.task != k.vm.current || k.finalizer != k.vm.runningFinalizers {
+		Error(errors.New("continuation can not be called by another task or finalizer"))
+	}
+
+	k.value = value
+	k.vm.WriteBarrier(k, value)
+	panic(escape{continuation: k})
+}
+
+func (k *ContinuationObject) Car() Object {
+	Error(errors.New("ContinuationObject does not have Car"))
+	return nil
+}
+
+func (k *ContinuationObject) Cdr() Object {
+	Error(errors.New("ContinuationObject does not have Cdr"))
+	return nil
+}
+
+func (k *ContinuationObject) IntegerValue() int {
+	Error(errors.New("ContinuationObject does not have IntegerValue"))
+	return 0
+}
+
+func (k *ContinuationObject) StringValue() string {
+	Error(errors.New("ContinuationObject does not have StringValue"))
+	return ""
+}
+
+func (k *ContinuationObject) BoolValue() bool {
+	Error(errors.New("ContinuationObject does not have BoolValue"))
+	return false
+}
+
+func (k *ContinuationObject) String() string {
+	return "continuation"
+}
+
+func (k *ContinuationObject) Type() ObjectType {
+	return TypeContinuation
+}
+
+func (k *ContinuationObject) Mark() {
+	k.marked = true
+}
+
+func (k *ContinuationObject) UnMark() {
+	k.marked = false
+}
+
+func (k *ContinuationObject) IsMarked() bool {
+	return k.marked
+}
+
+func (k *ContinuationObject) References() []Object {
+	if k.value == nil {
+		return nil
+	}
+	return []Object{k.value}
 }
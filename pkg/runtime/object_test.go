@@ -0,0 +1,36 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// integers are immediate, so compiled arithmetic does not allocate at all
+func TestIntegersAreNotAllocated(t *testing.T) {
+	for _, mode := range gcModes {
+		vm := runtime.NewVM(
+			runtime.WithGCMode(mode),
+			runtime.WithEvalMode(runtime.ModeBytecode),
+			runtime.WithStdout(ioutil.Discard),
+		)
+		runVM(t, vm, "(define fib (lambda (n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))))")
+		fib, _ := vm.Lookup("fib")
+
+		before := vm.GCStats()
+		result, err := vm.Apply(fib, runtime.NewIntegerObject(20))
+		if err != nil {
+			t.Fatal(err)
+		}
+		after := vm.GCStats()
+
+		if result.IntegerValue() != 6765 {
+			t.Errorf("%s: (fib 20) = %s", mode, result)
+		}
+		if after.Collections != before.Collections || vm.Heap().Objects() != before.Live {
+			t.Errorf("%s: (fib 20) allocated, %d collections, %d -> %d objects",
+				mode, after.Collections-before.Collections, before.Live, vm.Heap().Objects())
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+// OptLevel selects optimizations done on parsed forms before they run
+type OptLevel int
+
+const (
+	// O0 runs forms as they were parsed
+	O0 OptLevel = iota
+	// O1 folds constant arithmetic and comparisons, inlines arithmetic
+	// builtins and eliminates dead if branches
+	O1
+)
+
+func WithOptimization(level OptLevel) Option {
+	return func(v *VM) {
+		v.optLevel = level
+	}
+}
+
+// primitive describes arguments accepted by a builtin which can be folded,
+// max -1 means any number of arguments
+type primitive struct {
+	min int
+	max int
+}
+
+var primitives = map[string]primitive{
+	"+": {0, -1},
+	"-": {1, -1},
+	"*": {0, -1},
+	"=": {2, 2},
+	"<": {2, 2},
+	">": {2, 2},
+}
+
+// optimizer rewrites parsed forms, scopes hold parameters of enclosing
+// lambdas which shadow builtins
+type optimizer struct {
+	vm     *VM
+	scope  *HandleScope
+	scopes [][]string
+}
+
+// Optimize returns parsed form rewritten according to optimization level of
+// VM. Calls of arithmetic builtins with constant arguments are replaced by
+// their result, other calls of arithmetic builtins refer to the builtin
+// directly instead of looking it up and if with constant condition is
+// replaced by the branch it takes. Builtins are inlined only while no define
+// of their name was seen.
+func (v *VM) Optimize(form Object) Object {
+	if v.optLevel < O1 {
+		return form
+	}
+
+	scope := v.OpenScope()
+	defer scope.Close()
+	scope.Root(form)
+
+	o := &optimizer{vm: v, scope: scope}
+	return o.optimize(form)
+}
+
+func (o *optimizer) optimize(form Object) Object {
+	if form.Type() != TypeCons {
+		return form
+	}
+
+	head := form.Car()
+	args := listToSlice(form.Cdr())
+
+	if syntax, found := o.syntax(head); found {
+		switch syntax.name {
+		case "if":
+			return o.optimizeIf(form, args)
+		case "define":
+			if len(args) == 2 && args[0].Type() == TypeSymbol {
+				o.vm.global(args[0].StringValue()).declared = true
+			}
+			return o.rewrite(form, head, args, 1)
+		case "lambda":
+			if params, ok := o.params(args); ok {
+				o.scopes = append(o.scopes, params)
+				defer func() {
+					o.scopes = o.scopes[:len(o.scopes)-1]
+				}()
+				return o.rewrite(form, head, args, 1)
+			}
+		}
+		return form
+	}
+
+	if f, found := o.primitive(head); found {
+		form = o.rewrite(form, f, args, 0)
+		return o.fold(form, f)
+	}
+
+	// arguments are evaluated only if head turns out to be a function,
+	// other lists evaluate to themselves and have to stay as they are
+	if head.Type() == TypeSymbol && !o.isLocal(head.StringValue()) {
+		if value, found := o.vm.Lookup(head.StringValue()); found && isFunction(value) {
+			return o.rewrite(form, head, args, 0)
+		}
+	}
+
+	return form
+}
+
+func (o *optimizer) optimizeIf(form Object, args []Object) Object {
+	if len(args) != 3 {
+		return form
+	}
+
+	cond := o.scope.Root(o.optimize(args[0]))
+	if cond.Type() == TypeBool {
+		if cond.BoolValue() {
+			return o.optimize(args[1])
+		}
+		return o.optimize(args[2])
+	}
+
+	args[0] = cond
+	return o.rewrite(form, form.Car(), args, 1)
+}
+
+// rewrite optimizes arguments starting at index from and stores head and
+// arguments into the form. Parsed forms are not shared, so they are
+// rewritten in place.
+func (o *optimizer) rewrite(form, head Object, args []Object, from int) Object {
+	for i := from; i < len(args); i++ {
+		args[i] = o.scope.Root(o.optimize(args[i]))
+	}
+
+	o.setCar(form, head)
+	list := form.Cdr()
+	for _, arg := range args {
+		o.setCar(list, arg)
+		list = list.Cdr()
+	}
+
+	return form
+}
+
+func (o *optimizer) setCar(list, value Object) {
+	c := list.(*ConsObject)
+	if c.car != value {
+		c.car = value
+		o.vm.WriteBarrier(c, value)
+	}
+}
+
+// fold replaces call of primitive with constant arguments by its result
+func (o *optimizer) fold(form Object, f *FunctionObject) Object {
+	args := listToSlice(form.Cdr())
+
+	p := primitives[f.name]
+	if len(args) < p.min || (p.max >= 0 && len(args) > p.max) {
+		return form
+	}
+	for _, arg := range args {
+		if arg.Type() != TypeInteger {
+			return form
+		}
+	}
+
+	for _, arg := range args {
+		o.vm.Stack().Push(arg)
+	}
+	return f.EvaluateFunction(len(args))
+}
+
+// primitive returns builtin which can be inlined in place of head
+func (o *optimizer) primitive(head Object) (*FunctionObject, bool) {
+	if head.Type() != TypeSymbol || o.isLocal(head.StringValue()) {
+		return nil, false
+	}
+
+	name := head.StringValue()
+	if _, found := primitives[name]; !found {
+		return nil, false
+	}
+
+	g := o.vm.global(name)
+	f, ok := g.value.(*FunctionObject)
+	if !ok || f.name != name || g.declared {
+		return nil, false
+	}
+
+	g.inlined = true
+	return f, true
+}
+
+func (o *optimizer) syntax(head Object) (*SyntaxObject, bool) {
+	if head.Type() != TypeSymbol || o.isLocal(head.StringValue()) {
+		return nil, false
+	}
+
+	value, found := o.vm.Lookup(head.StringValue())
+	if !found {
+		return nil, false
+	}
+
+	syntax, ok := value.(*SyntaxObject)
+	return syntax, ok
+}
+
+func (o *optimizer) isLocal(name string) bool {
+	for _, scope := range o.scopes {
+		for _, n := range scope {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *optimizer) params(args []Object) ([]string, bool) {
+	if len(args) == 0 || (args[0].Type() != TypeCons && args[0].Type() != TypeNil) {
+		return nil, false
+	}
+
+	params := make([]string, 0)
+	for _, param := range listToSlice(args[0]) {
+		if param.Type() != TypeSymbol {
+			return nil, false
+		}
+		params = append(params, param.StringValue())
+	}
+	return params, true
+}
+
+// define stores value of global variable and warns when the variable was
+// inlined, code optimized before keeps using the inlined builtin
+func (v *VM) define(g *Global, value Object) {
+	if g.inlined {
+		v.Warning(errors.Errorf("builtin %s was inlined, redefinition does not affect optimized code", g.name))
+		g.inlined = false
+	}
+	g.declared = true
+	g.value = value
+}
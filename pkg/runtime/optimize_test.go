@@ -0,0 +1,91 @@
+package runtime_test
+
+import (
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/parser"
+	"lisp-interpreter/pkg/runtime"
+)
+
+// optimize parses src and returns the form optimized by vm
+func optimize(t *testing.T, vm *runtime.VM, src string) runtime.Object {
+	t.Helper()
+
+	var form runtime.Object
+	err := vm.Try(func() {
+		form = parser.NewParser(vm, strings.NewReader(src)).Parse()
+		form = vm.Optimize(form)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func TestOptimizeFoldsConstants(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithOptimization(runtime.O1))
+
+	form := optimize(t, vm, "(+ 1 (* 2 3) (- 10 4))")
+	if form.Type() != runtime.TypeInteger || form.IntegerValue() != 13 {
+		t.Errorf("folded to %s, want 13", form)
+	}
+
+	form = optimize(t, vm, "(< 1 2)")
+	if form.Type() != runtime.TypeBool || !form.BoolValue() {
+		t.Errorf("folded to %s, want T", form)
+	}
+}
+
+func TestOptimizeSkipsRedefinedBuiltin(t *testing.T) {
+	var out strings.Builder
+	vm := runtime.NewVM(runtime.WithOptimization(runtime.O1), runtime.WithStdout(&out), runtime.WithStderr(&out))
+
+	form := optimize(t, vm, "(* x 2)")
+	if form.Car().Type() != runtime.TypeFunction {
+		t.Errorf("builtin * is not inlined: %s", form)
+	}
+
+	runVM(t, vm, "(define * +)")
+	form = optimize(t, vm, "(* x 2)")
+	if form.Car().Type() != runtime.TypeSymbol {
+		t.Errorf("redefined builtin * is inlined: %s", form)
+	}
+	form = optimize(t, vm, "(* 3 2)")
+	if form.Type() != runtime.TypeCons {
+		t.Errorf("call of redefined builtin * is folded to %s", form)
+	}
+
+	// define seen by the optimizer before it runs
+	optimize(t, vm, "(define - (lambda (a b) a))")
+	form = optimize(t, vm, "(- 3 2)")
+	if form.Type() != runtime.TypeCons {
+		t.Errorf("call of builtin - defined later is folded to %s", form)
+	}
+}
+
+func TestOptimizeEliminatesDeadIf(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithOptimization(runtime.O1))
+
+	for src, want := range map[string]string{
+		"(if (= 1 1) a b)":       "a",
+		"(if (> 1 2) a (car b))": "(car b)",
+		"(if (= 2 (+ 1 1)) 1 b)": "1",
+	} {
+		if form := optimize(t, vm, src); form.String() != want {
+			t.Errorf("%s optimized to %s, want %s", src, form, want)
+		}
+	}
+
+	if form := optimize(t, vm, "(if (< x 2) a b)"); form.Type() != runtime.TypeCons || form.Car().String() != "if" {
+		t.Errorf("if with unknown condition optimized to %s", form)
+	}
+}
+
+func TestOptimizeO0KeepsForms(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithOptimization(runtime.O0))
+
+	if form := optimize(t, vm, "(if (= 1 1) (+ 1 2) b)"); form.Type() != runtime.TypeCons || form.Car().Type() != runtime.TypeSymbol {
+		t.Errorf("form optimized to %s at O0", form)
+	}
+}
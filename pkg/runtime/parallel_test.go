@@ -0,0 +1,58 @@
+package runtime_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// TestParallelVMs runs VMs in parallel, run it with -race
+func TestParallelVMs(t *testing.T) {
+	const n = 16
+
+	var wg sync.WaitGroup
+	stdout := make([]bytes.Buffer, n)
+	stderr := make([]bytes.Buffer, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			vm := runtime.NewVM(
+				runtime.WithGCMode(gcModes[i%len(gcModes)]),
+				runtime.WithStdout(&stdout[i]),
+				runtime.WithStderr(&stderr[i]),
+				runtime.WithDebug(true),
+			)
+			src := fmt.Sprintf(`
+(define fib (lambda (n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))))
+(fib %d)
+(gc)
+undefined
+`, i)
+			runVM(t, vm, src)
+		}(i)
+	}
+	wg.Wait()
+
+	fib := []int{0, 1}
+	for i := 2; i < n; i++ {
+		fib = append(fib, fib[i-1]+fib[i-2])
+	}
+
+	for i := 0; i < n; i++ {
+		out := fmt.Sprintf("\n%d\n\n\n", fib[i])
+		if got := stdout[i].String(); got != out {
+			t.Errorf("vm %d: stdout %q, want %q", i, got, out)
+		}
+		for _, want := range []string{"# of objects", "undefined"} {
+			if !strings.Contains(stderr[i].String(), want) {
+				t.Errorf("vm %d: stderr %q does not contain %q", i, stderr[i].String(), want)
+			}
+		}
+	}
+}
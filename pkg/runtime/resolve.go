@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Global is a cell holding value of a global variable. Resolved symbols and
+// compiled code keep the cell, so they do not look the name up again.
+type Global struct {
+	name  string
+	value Object
+	// declared is set when a define of the variable was seen, it suppresses
+	// unbound variable warnings and inlining of builtins
+	declared bool
+	// inlined is set when optimizer inlined builtin stored in the variable
+	inlined bool
+}
+
+func (g *Global) Name() string {
+	return g.name
+}
+
+// Value returns value of the variable or nil if it is not defined
+func (g *Global) Value() Object {
+	return g.value
+}
+
+// global returns cell of global variable, the cell is created if needed
+func (v *VM) global(name string) *Global {
+	g, found := v.variables[name]
+	if !found {
+		g = &Global{name: name}
+		v.variables[name] = g
+	}
+	return g
+}
+
+// Lookup returns value of global variable
+func (v *VM) Lookup(name string) (Object, bool) {
+	g, found := v.variables[name]
+	if !found || g.value == nil {
+		return nil, false
+	}
+	return g.value, true
+}
+
+// resolver assigns lexical addresses to symbols of parsed form. Parameters of
+// every lambda form one level of the environment chain.
+type resolver struct {
+	vm     *VM
+	scopes [][]string
+	// quiet is set inside lists which are data, their symbols are not
+	// evaluated so undefined ones are not reported
+	quiet bool
+}
+
+// Resolve resolves every variable reference in parsed form to a local
+// variable addressed by depth in environment chain and index, or to a cell
+// of global variable. References to variables which are not defined are
+// reported as warnings before the form runs.
+func (v *VM) Resolve(form Object) {
+	r := &resolver{vm: v}
+	r.resolve(form)
+}
+
+func (r *resolver) resolve(form Object) {
+	switch form.Type() {
+	case TypeSymbol:
+		r.resolveSymbol(form.(*SymbolObject))
+	case TypeCons:
+		r.resolveList(form)
+	}
+}
+
+func (r *resolver) resolveSymbol(s *SymbolObject) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		scope := r.scopes[len(r.scopes)-1-depth]
+		for index, name := range scope {
+			if name == s.name {
+				s.local = true
+				s.depth = depth
+				s.index = index
+				s.global = nil
+				return
+			}
+		}
+	}
+
+	g := r.vm.global(s.name)
+	if g.value == nil && !g.declared && !r.quiet {
+		r.vm.Warning(errors.Errorf("variable %s is not defined", s.name))
+	}
+	s.local = false
+	s.global = g
+}
+
+// syntax returns syntax a list starting by head evaluates with
+func (r *resolver) syntax(head Object) (*SyntaxObject, bool) {
+	if head.Type() != TypeSymbol || r.isLocal(head.StringValue()) {
+		return nil, false
+	}
+
+	o, found := r.vm.Lookup(head.StringValue())
+	if !found {
+		return nil, false
+	}
+
+	syntax, ok := o.(*SyntaxObject)
+	return syntax, ok
+}
+
+func (r *resolver) isLocal(name string) bool {
+	for _, scope := range r.scopes {
+		for _, n := range scope {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *resolver) resolveList(form Object) {
+	head := form.Car()
+	args := listToSlice(form.Cdr())
+
+	if syntax, found := r.syntax(head); found {
+		r.resolveSymbol(head.(*SymbolObject))
+
+		switch syntax.name {
+		case "if":
+			for _, arg := range args {
+				r.resolve(arg)
+			}
+		case "define":
+			if len(args) == 2 && args[0].Type() == TypeSymbol {
+				r.vm.global(args[0].StringValue()).declared = true
+				r.resolve(args[1])
+			}
+		case "lambda":
+			r.resolveLambda(args)
+		}
+		// other syntax gets its arguments unevaluated
+		return
+	}
+
+	// lists which do not start by a function evaluate to themselves,
+	// optimizer puts inlined builtins directly at the head
+	if isFunction(head) {
+		for _, arg := range args {
+			r.resolve(arg)
+		}
+		return
+	}
+	if head.Type() != TypeSymbol && head.Type() != TypeCons {
+		return
+	}
+
+	r.resolve(head)
+
+	quiet := r.quiet
+	r.quiet = quiet || !r.mayBeFunction(head)
+	for _, arg := range args {
+		r.resolve(arg)
+	}
+	r.quiet = quiet
+}
+
+// mayBeFunction reports whether head of list may evaluate to a function,
+// otherwise the list is data and its arguments are not evaluated
+func (r *resolver) mayBeFunction(head Object) bool {
+	if head.Type() != TypeSymbol || r.isLocal(head.StringValue()) {
+		return true
+	}
+
+	g := r.vm.global(head.StringValue())
+	if g.value == nil {
+		return g.declared
+	}
+	return isFunction(g.value)
+}
+
+func (r *resolver) resolveLambda(args []Object) {
+	if len(args) == 0 || (args[0].Type() != TypeCons && args[0].Type() != TypeNil) {
+		return
+	}
+
+	params := make([]string, 0)
+	for _, param := range listToSlice(args[0]) {
+		if param.Type() != TypeSymbol {
+			return
+		}
+		params = append(params, param.StringValue())
+	}
+
+	r.scopes = append(r.scopes, params)
+	for _, form := range args[1:] {
+		r.resolve(form)
+	}
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
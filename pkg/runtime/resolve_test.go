@@ -0,0 +1,22 @@
+package runtime_test
+
+import (
+	"strings"
+	"testing"
+)
+
+// only symbols which are evaluated are reported as undefined
+func TestResolveWarnings(t *testing.T) {
+	out := run(t, "(define n 1) (n a) (car (b c d)) (define f (lambda (x) x)) (f e)")
+
+	for _, name := range []string{"b", "e"} {
+		if !strings.Contains(out, "variable "+name+" is not defined") {
+			t.Errorf("%s is not reported: %q", name, out)
+		}
+	}
+	for _, name := range []string{"a", "c", "d"} {
+		if strings.Contains(out, "variable "+name+" is not defined") {
+			t.Errorf("%s in data list is reported: %q", name, out)
+		}
+	}
+}
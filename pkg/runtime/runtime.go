@@ -1,135 +1,491 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"lisp-interpreter/pkg/logger"
-)
 
-const StackMaxObjects = 256
+	"github.com/pkg/errors"
+)
 
 type VM struct {
-	memory    []Object
-	variables map[string]Object
+	heap      *Heap
+	variables map[string]*Global
+
+	// immortal singletons returned by allocation of nil, void and bools
+	nilObject   *NilObject
+	voidObject  *VoidObject
+	trueObject  *BoolObject
+	falseObject *BoolObject
 
 	stack *Stack
+	// handles are objects rooted by Go code, see HandleScope
+	handles []Object
+	// env is the environment of lambda evaluated by tree walking
+	env *EnvironmentObject
+
+	mode     EvalMode
+	optLevel OptLevel
+	// builtins installed into VM, allowed nil means all of the profile
+	profile Profile
+	allowed map[string]bool
+	denied  map[string]bool
+	// positions holds source positions of parsed forms
+	positions map[Object]Position
+
+	// stdout receives output of builtins, stderr errors, warnings and GC
+	// debug print, stdin is read by the REPL
+	stdout io.Writer
+	stderr io.Writer
+	stdin  io.Reader
+	debug  bool
+	logger *logger.Logger
+
+	limits Limits
+	// state of the running evaluation checked against limits
+	evaluating bool
+	ctx        context.Context
+	steps      int
+	depth      int
+
+	// tasks started by spawn take turns holding lock, see task
+	lock    sync.Mutex
+	cond    *sync.Cond
+	tasks   []*task
+	current *task
+	// waiting is the number of tasks waiting since the last notify
+	waiting int
+	// stopping holds collected coroutines whose goroutines have to be stopped
+	stopping []*CoroutineObject
+
+	gcMode        GCMode
+	gcSliceBudget int
+	collector     collector
+
+	// state of collection cycle which may span several gc calls
+	gcInProgress bool
+	gcBefore     int
+	gcMaxPause   time.Duration
+
+	gcStats GCStats
+
+	weakBoxes         map[*WeakBoxObject]bool
+	weakTables        map[*HashTableObject]bool
+	finalizers        []finalizer
+	finalizing        []finalizer
+	runningFinalizers bool
+
+	gcStress        bool
+	allocationSites map[Object]string
+	poisoned        map[Object]string
+	freedBlocks     map[int]Object
+	violations      []error
+}
+
+type Option func(*VM)
+
+type EvalMode int
+
+const (
+	// ModeTree evaluates parsed forms by walking them
+	ModeTree EvalMode = iota
+	// ModeBytecode compiles parsed forms and runs the bytecode
+	ModeBytecode
+)
+
+var evalModeNames = map[EvalMode]string{
+	ModeTree:     "tree",
+	ModeBytecode: "bytecode",
+}
+
+func ParseEvalMode(name string) (EvalMode, error) {
+	for mode, modeName := range evalModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return 0, errors.Errorf("unknown mode %s", name)
+}
+
+func (m EvalMode) String() string {
+	return evalModeNames[m]
+}
+
+func WithEvalMode(mode EvalMode) Option {
+	return func(v *VM) {
+		v.mode = mode
+	}
+}
+
+func WithGCMode(mode GCMode) Option {
+	return func(v *VM) {
+		v.gcMode = mode
+	}
+}
 
-	memoryObjects  int
-	lastBlockIndex int
-	gcThreshold    int
+// WithGCSliceBudget sets how many objects incremental GC scans per slice
+func WithGCSliceBudget(objects int) Option {
+	return func(v *VM) {
+		v.gcSliceBudget = objects
+	}
+}
+
+// WithStdout sets writer receiving results and output of builtins,
+// os.Stdout by default
+func WithStdout(w io.Writer) Option {
+	return func(v *VM) {
+		v.stdout = w
+	}
+}
+
+// WithStderr sets writer receiving errors, warnings and GC debug print,
+// os.Stderr by default
+func WithStderr(w io.Writer) Option {
+	return func(v *VM) {
+		v.stderr = w
+	}
+}
+
+// WithStdin sets reader the REPL reads forms from, os.Stdin by default
+func WithStdin(r io.Reader) Option {
+	return func(v *VM) {
+		v.stdin = r
+	}
+}
+
+// WithDebug turns printing of GC runs on
+func WithDebug(debug bool) Option {
+	return func(v *VM) {
+		v.debug = debug
+	}
 }
 
-func NewVM() *VM {
+func NewVM(options ...Option) *VM {
 	vm := &VM{
-		memory:         make([]Object, StackMaxObjects),
-		variables:      make(map[string]Object),
-		stack:          NewStack(),
-		memoryObjects:  0,
-		lastBlockIndex: -1,
-		gcThreshold:    10,
-	}
-
-	NewFunctionObject("+", builtinPlus).Allocate(vm)
-	NewFunctionObject("-", builtinMinus).Allocate(vm)
-	NewFunctionObject("*", builtinTimes).Allocate(vm)
-	NewFunctionObject("=", builtinEquals).Allocate(vm)
-	NewFunctionObject("<", builtinLessThan).Allocate(vm)
-	NewFunctionObject(">", builtinGreaterThan).Allocate(vm)
-	NewFunctionObject("car", builtinCar).Allocate(vm)
-	NewFunctionObject("cdr", builtinCdr).Allocate(vm)
-	NewSyntaxObject("if", builtinIf).Allocate(vm)
-	NewSyntaxObject("define", builtinDefine).Allocate(vm)
+		heap:          NewHeap(HeapInitialObjects),
+		variables:     make(map[string]*Global),
+		nilObject:     &NilObject{},
+		voidObject:    &VoidObject{},
+		trueObject:    &BoolObject{value: true},
+		falseObject:   &BoolObject{value: false},
+		stack:         NewStack(),
+		handles:       make([]Object, 0),
+		positions:     make(map[Object]Position),
+		stdout:        os.Stdout,
+		stderr:        os.Stderr,
+		stdin:         os.Stdin,
+		profile:       ProfileFull,
+		denied:        make(map[string]bool),
+		gcMode:        GCMarkSweep,
+		gcSliceBudget: GCSliceBudget,
+		weakBoxes:     make(map[*WeakBoxObject]bool),
+		weakTables:    make(map[*HashTableObject]bool),
+		finalizers:    make([]finalizer, 0),
+		finalizing:    make([]finalizer, 0),
+
+		allocationSites: make(map[Object]string),
+		poisoned:        make(map[Object]string),
+		freedBlocks:     make(map[int]Object),
+		violations:      make([]error, 0),
+	}
+
+	for _, option := range options {
+		option(vm)
+	}
+	vm.collector = newCollector(vm.gcMode, vm.gcSliceBudget)
+	vm.logger = logger.New(vm.stderr, vm.debug)
+	newScheduler(vm)
+
+	vm.installBuiltins()
 
 	return vm
 }
 
 func (v *VM) AllocateObject(o Object) {
-	if v.memoryObjects >= v.gcThreshold || v.memoryObjects == StackMaxObjects {
-		v.gc()
+	overLimit := v.overHeapLimit()
+	if v.gcStress || v.collector.shouldCollect(v) || v.heap.IsFull() || overLimit {
+		// references of o are not reachable from roots until o is allocated
+		scope := v.OpenScope()
+		for _, ref := range o.References() {
+			scope.Root(ref)
+		}
+
+		v.gc(v.gcStress || v.heap.IsFull() || overLimit)
+		scope.Close()
+
+		if overLimit {
+			v.checkHeapLimit()
+		}
+	}
+
+	if v.heap.IsFull() {
+		v.heap.grow(v.heap.Capacity())
 	}
 
-	blockIndex := v.findFreeBlock()
+	blockIndex := v.heap.Allocate(o)
+	v.collector.allocated(v, o, blockIndex)
 
-	v.memory[blockIndex] = o
-	v.lastBlockIndex = blockIndex
-	v.memoryObjects++
+	if v.gcStress {
+		// freed object is forgotten once its block is reused, so stress mode
+		// keeps at most one freed object per block
+		if freed, found := v.freedBlocks[blockIndex]; found {
+			delete(v.poisoned, freed)
+			delete(v.freedBlocks, blockIndex)
+		}
+		v.allocationSites[o] = allocationSite()
+	}
+}
+
+// WriteBarrier has to be called whenever reference to child is stored into
+// an already allocated parent object.
+func (v *VM) WriteBarrier(parent, child Object) {
+	v.collector.writeBarrier(v, parent, child)
 }
 
 func (v *VM) FreeObject(blockIndex int) {
-	o := v.memory[blockIndex]
+	o := v.heap.Get(blockIndex)
 	if o == nil {
-		fmt.Println("error: double free")
-		os.Exit(1)
+		v.reportViolation(errors.Errorf("double free of block %d", blockIndex))
+		return
 	}
 
-	for key, varO := range v.variables {
-		if o == varO {
-			delete(v.variables, key)
+	if v.gcStress {
+		v.poisoned[o] = v.allocationSites[o]
+		v.freedBlocks[blockIndex] = o
+		delete(v.allocationSites, o)
+	}
+
+	if c, ok := o.(*CoroutineObject); ok {
+		v.freeCoroutine(c)
+	}
+
+	delete(v.positions, o)
+	v.heap.Free(blockIndex)
+	v.gcStats.Freed++
+}
+
+// Eval evaluates parsed form using the evaluation mode of VM. Error which
+// aborted evaluation is printed and void is returned.
+func (v *VM) Eval(form Object) Object {
+	result, err := v.EvalForm(form)
+	if err != nil {
+		v.PrintError(err)
+		return NewVoidObject().Allocate(v)
+	}
+	return result
+}
+
+// EvalForm evaluates parsed form and returns error which aborted evaluation
+func (v *VM) EvalForm(form Object) (Object, error) {
+	return v.EvalContext(context.Background(), form)
+}
+
+// Apply calls function with arguments and returns error which aborted it
+func (v *VM) Apply(function Object, args ...Object) (Object, error) {
+	return v.ApplyContext(context.Background(), function, args...)
+}
+
+// ApplyContext calls function like Apply and aborts the call when ctx is done
+func (v *VM) ApplyContext(ctx context.Context, function Object, args ...Object) (Object, error) {
+	if !isFunction(function) {
+		return nil, errors.Errorf("%s is not a function", function.Type())
+	}
+
+	var result Object
+	err := v.limited(ctx, func() {
+		for _, arg := range args {
+			v.stack.Push(arg)
 		}
+		result = function.EvaluateFunction(len(args))
+	})
+	return result, err
+}
+
+// Define stores value into global variable
+func (v *VM) Define(name string, value Object) {
+	v.define(v.global(name), value)
+}
+
+func (v *VM) eval(form Object) Object {
+	scope := v.OpenScope()
+	defer scope.Close()
+	scope.Root(form)
+
+	form = scope.Root(v.Optimize(form))
+	v.Resolve(form)
+	if v.mode == ModeBytecode {
+		code := v.Compile(form)
+		return NewClosureObject(code, nil).Allocate(v).EvaluateFunction(0)
+	}
+
+	return form.Evaluate()
+}
+
+// Position is a position in source code, lines and columns start at 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return "-"
 	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// SetPosition remembers where parsed form starts in source code
+func (v *VM) SetPosition(o Object, position Position) {
+	// immortal objects are shared and never freed
+	if isImmortal(o) {
+		return
+	}
+	v.positions[o] = position
+}
+
+func (v *VM) Position(o Object) (Position, bool) {
+	position, found := v.positions[o]
+	return position, found
+}
+
+func (v *VM) Stdout() io.Writer {
+	return v.stdout
+}
 
-	v.memory[blockIndex] = nil
-	v.memoryObjects--
+func (v *VM) Stderr() io.Writer {
+	return v.stderr
+}
+
+func (v *VM) Stdin() io.Reader {
+	return v.stdin
 }
 
 func (v *VM) Stack() *Stack {
 	return v.stack
 }
 
-func (v *VM) findFreeBlock() int {
-	blockIndex := v.lastBlockIndex
+func (v *VM) Heap() *Heap {
+	return v.heap
+}
 
-	for {
-		blockIndex++
-		if blockIndex >= StackMaxObjects {
-			blockIndex = 0
-		}
-		if blockIndex == v.lastBlockIndex {
-			fmt.Println("error: out of memory")
-			os.Exit(1)
-		}
-		if v.memory[blockIndex] == nil {
-			return blockIndex
+func (v *VM) GCMode() GCMode {
+	return v.gcMode
+}
+
+// GC runs a full collection, finishing any collection in progress
+func (v *VM) GC() {
+	v.gc(true)
+}
+
+func (v *VM) gc(full bool) {
+	if !v.gcInProgress {
+		v.gcInProgress = true
+		v.gcBefore = v.heap.Objects()
+		v.gcMaxPause = 0
+	}
+
+	start := time.Now()
+	done, major := v.collector.collect(v, full)
+	if pause := time.Since(start); pause > v.gcMaxPause {
+		v.gcMaxPause = pause
+	}
+
+	if !done {
+		return
+	}
+
+	v.gcInProgress = false
+	// minor collections leave old objects untouched, so the amount of live
+	// objects is known only after the whole heap was collected
+	if major {
+		v.heap.Resize()
+	}
+	v.gcStats.record(v.gcMaxPause, v.collector.gcThreshold())
+
+	v.logger.Logf("# of objects %d->%d, heap size %d, fragmentation %.2f, max pause %v",
+		v.gcBefore, v.heap.Objects(), v.heap.Capacity(), v.heap.Fragmentation(), v.gcMaxPause)
+
+	if v.gcStress {
+		if err := v.VerifyHeap(); err != nil {
+			v.reportViolation(err)
 		}
 	}
+
+	v.clearWeakReferences()
+	v.runFinalizers()
+	v.stopCoroutines()
 }
 
-func (v *VM) gc() {
-	before := v.memoryObjects
+// evalError carries error passed to Error up to the innermost VM.Try
+type evalError struct {
+	err error
+}
 
-	v.mark()
-	v.sweep()
+// Error aborts evaluation with err, the error is returned by the innermost
+// VM.Try
+func Error(err error) {
+	panic(evalError{err: err})
+}
 
-	v.gcThreshold = v.memoryObjects * 2
+// PrintError prints error the way the REPL does
+func (v *VM) PrintError(err error) {
+	fmt.Fprintf(v.stderr, "error: %v\n", err)
+}
 
-	logger.Logf("# of objects %d->%d", before, v.memoryObjects)
+// checkpoint is state of the running task restored when evaluation is
+// aborted or escapes
+type checkpoint struct {
+	stack   int
+	handles int
+	env     *EnvironmentObject
+	depth   int
 }
 
-func (v *VM) mark() {
-	for _, v := range v.variables {
-		v.Mark()
+func (v *VM) checkpoint() checkpoint {
+	return checkpoint{
+		stack:   len(v.stack.stack),
+		handles: len(v.handles),
+		env:     v.env,
+		depth:   v.depth,
 	}
+}
 
-	for _, o := range v.stack.stack {
-		o.Mark()
-	}
+func (v *VM) rollback(c checkpoint) {
+	v.stack.stack = v.stack.stack[:c.stack]
+	v.handles = v.handles[:c.handles]
+	v.env = c.env
+	v.depth = c.depth
 }
 
-func (v *VM) sweep() {
-	for i, o := range v.memory {
-		if o == nil {
-			continue
+// Try runs f and returns error which aborted it. Stack, handles, environment
+// and call depth of VM are restored to their state before f.
+func (v *VM) Try(f func()) (err error) {
+	c := v.checkpoint()
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
 		}
 
-		if o.IsMarked() {
-			o.UnMark()
-		} else {
-			v.FreeObject(i)
+		e, ok := r.(evalError)
+		if !ok {
+			panic(r)
 		}
-	}
+
+		v.rollback(c)
+		err = e.err
+	}()
+
+	f()
+	return nil
 }
 
-func Error(err error) {
-	fmt.Printf("error: %v ", err)
+func (v *VM) Warning(err error) {
+	fmt.Fprintf(v.stderr, "warning: %v\n", err)
 }
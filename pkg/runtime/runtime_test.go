@@ -0,0 +1,73 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/parser"
+	"lisp-interpreter/pkg/runtime"
+)
+
+var gcModes = []runtime.GCMode{
+	runtime.GCMarkSweep,
+	runtime.GCGenerational,
+	runtime.GCIncremental,
+	runtime.GCCopying,
+}
+
+// run evaluates every form of src and returns printed results and errors,
+// one form per line
+func run(t *testing.T, src string, options ...runtime.Option) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	options = append(options, runtime.WithStdout(&out), runtime.WithStderr(&out))
+	vm := runtime.NewVM(options...)
+	runVM(t, vm, src)
+	return out.String()
+}
+
+// runVM is run which prints to the output of vm, it can be called from any
+// goroutine
+func runVM(t *testing.T, vm *runtime.VM, src string) {
+	t.Helper()
+
+	p := parser.NewParser(vm, strings.NewReader(src))
+	for !p.IsEOF() {
+		var form runtime.Object
+		if err := vm.Try(func() { form = p.Parse() }); err != nil {
+			t.Error(err)
+			return
+		}
+		if p.IsEOF() && form.Type() == runtime.TypeNil {
+			return
+		}
+
+		fmt.Fprintln(vm.Stdout(), vm.Eval(form))
+	}
+}
+
+// evalContext evaluates every form of src with EvalContext and returns the
+// first error
+func evalContext(ctx context.Context, t *testing.T, vm *runtime.VM, src string) error {
+	t.Helper()
+
+	p := parser.NewParser(vm, strings.NewReader(src))
+	for !p.IsEOF() {
+		var form runtime.Object
+		if err := vm.Try(func() { form = p.Parse() }); err != nil {
+			t.Fatal(err)
+		}
+		if p.IsEOF() && form.Type() == runtime.TypeNil {
+			return nil
+		}
+
+		if _, err := vm.EvalContext(ctx, form); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Profile selects builtins installed into VM. Every profile contains the
+// builtins of the profiles before it.
+type Profile int
+
+const (
+	// ProfilePure has only arithmetic, comparisons, car, cdr, if, define and
+	// lambda
+	ProfilePure Profile = iota
+	// ProfileNoIO adds builtins which do not read or write files or print,
+	// like hash tables, weak references, finalizers and GC control
+	ProfileNoIO
+	// ProfileFull has every builtin
+	ProfileFull
+)
+
+var profileNames = map[Profile]string{
+	ProfilePure: "pure",
+	ProfileNoIO: "noio",
+	ProfileFull: "full",
+}
+
+func ParseProfile(name string) (Profile, error) {
+	for profile, profileName := range profileNames {
+		if profileName == name {
+			return profile, nil
+		}
+	}
+	return 0, errors.Errorf("unknown profile %s", name)
+}
+
+func (p Profile) String() string {
+	return profileNames[p]
+}
+
+// builtin is a builtin function or syntax together with the first profile
+// which contains it
+type builtin struct {
+	name    string
+	fn      func(int, *VM) Object
+	syntax  bool
+	profile Profile
+}
+
+var builtins = []builtin{
+	{"+", builtinPlus, false, ProfilePure},
+	{"-", builtinMinus, false, ProfilePure},
+	{"*", builtinTimes, false, ProfilePure},
+	{"=", builtinEquals, false, ProfilePure},
+	{"<", builtinLessThan, false, ProfilePure},
+	{">", builtinGreaterThan, false, ProfilePure},
+	{"car", builtinCar, false, ProfilePure},
+	{"cdr", builtinCdr, false, ProfilePure},
+	{"if", builtinIf, true, ProfilePure},
+	{"define", builtinDefine, true, ProfilePure},
+	{"lambda", builtinLambda, true, ProfilePure},
+	{"gc", builtinGC, false, ProfileNoIO},
+	{"gc-stats", builtinGCStats, false, ProfileNoIO},
+	{"heap-dump", builtinHeapDump, false, ProfileFull},
+	{"disassemble", builtinDisassemble, false, ProfileFull},
+	{"make-weak-box", builtinMakeWeakBox, false, ProfileNoIO},
+	{"weak-box-value", builtinWeakBoxValue, false, ProfileNoIO},
+	{"make-hash-table", builtinMakeHashTable, false, ProfileNoIO},
+	{"make-weak-hash-table", builtinMakeWeakHashTable, false, ProfileNoIO},
+	{"hash-table-set!", builtinHashTableSet, false, ProfileNoIO},
+	{"hash-table-ref", builtinHashTableRef, false, ProfileNoIO},
+	{"hash-table-remove!", builtinHashTableRemove, false, ProfileNoIO},
+	{"hash-table-count", builtinHashTableCount, false, ProfileNoIO},
+	{"register-finalizer", builtinRegisterFinalizer, false, ProfileNoIO},
+	{"spawn", builtinSpawn, false, ProfileNoIO},
+	{"make-channel", builtinMakeChannel, false, ProfileNoIO},
+	{"send", builtinSend, false, ProfileNoIO},
+	{"receive", builtinReceive, false, ProfileNoIO},
+	{"close", builtinClose, false, ProfileNoIO},
+	{"select", builtinSelect, false, ProfileNoIO},
+	{"make-coroutine", builtinMakeCoroutine, false, ProfileNoIO},
+	{"make-generator", builtinMakeCoroutine, false, ProfileNoIO},
+	{"resume", builtinResume, false, ProfileNoIO},
+	{"yield", builtinYield, false, ProfileNoIO},
+	{"next", builtinNext, false, ProfileNoIO},
+	{"take", builtinTake, false, ProfileNoIO},
+	{"call-with-current-continuation", builtinCallCC, false, ProfileNoIO},
+	{"call/cc", builtinCallCC, false, ProfileNoIO},
+}
+
+// WithProfile selects builtins installed into VM, the default is ProfileFull
+func WithProfile(profile Profile) Option {
+	return func(v *VM) {
+		v.profile = profile
+	}
+}
+
+// WithAllowedBuiltins installs only the named builtins of the profile, syntax
+// like if, define and lambda has to be listed too
+func WithAllowedBuiltins(names ...string) Option {
+	return func(v *VM) {
+		v.allowed = make(map[string]bool)
+		for _, name := range names {
+			v.allowed[name] = true
+		}
+	}
+}
+
+// WithDeniedBuiltins does not install the named builtins
+func WithDeniedBuiltins(names ...string) Option {
+	return func(v *VM) {
+		for _, name := range names {
+			v.denied[name] = true
+		}
+	}
+}
+
+// ParseBuiltins splits comma separated list of builtins for
+// WithAllowedBuiltins and WithDeniedBuiltins and checks that they exist
+func ParseBuiltins(list string) ([]string, error) {
+	names := strings.Split(list, ",")
+	for _, name := range names {
+		if !isBuiltinName(name) {
+			return nil, errors.Errorf("unknown builtin %s", name)
+		}
+	}
+	return names, nil
+}
+
+func isBuiltinName(name string) bool {
+	for _, b := range builtins {
+		if b.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// installBuiltins defines builtins selected by profile, allow and deny list
+func (v *VM) installBuiltins() {
+	for _, b := range builtins {
+		if b.profile > v.profile || v.denied[b.name] {
+			continue
+		}
+		if v.allowed != nil && !v.allowed[b.name] {
+			continue
+		}
+
+		if b.syntax {
+			NewSyntaxObject(b.name, b.fn).Allocate(v)
+		} else {
+			NewFunctionObject(b.name, b.fn).Allocate(v)
+		}
+	}
+}
+
+// Builtins returns names of builtins installed into VM
+func (v *VM) Builtins() []string {
+	names := make([]string, 0)
+	for _, b := range builtins {
+		if value, found := v.Lookup(b.name); found && isBuiltin(value, b.name) {
+			names = append(names, b.name)
+		}
+	}
+	return names
+}
+
+func isBuiltin(o Object, name string) bool {
+	switch b := o.(type) {
+	case *FunctionObject:
+		return b.name == name
+	case *SyntaxObject:
+		return b.name == name
+	}
+	return false
+}
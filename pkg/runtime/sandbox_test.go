@@ -0,0 +1,75 @@
+package runtime_test
+
+import (
+	"reflect"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// ioBuiltins print or write files
+var ioBuiltins = []string{"heap-dump", "disassemble"}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProfiles(t *testing.T) {
+	full := runtime.NewVM().Builtins()
+	for _, name := range ioBuiltins {
+		if !contains(full, name) {
+			t.Errorf("full profile does not have %s", name)
+		}
+	}
+
+	for _, profile := range []runtime.Profile{runtime.ProfilePure, runtime.ProfileNoIO} {
+		builtins := runtime.NewVM(runtime.WithProfile(profile)).Builtins()
+		for _, name := range ioBuiltins {
+			if contains(builtins, name) {
+				t.Errorf("%s profile has %s", profile, name)
+			}
+		}
+		for _, name := range []string{"+", "if", "define", "lambda"} {
+			if !contains(builtins, name) {
+				t.Errorf("%s profile does not have %s", profile, name)
+			}
+		}
+	}
+
+	if contains(runtime.NewVM(runtime.WithProfile(runtime.ProfilePure)).Builtins(), "make-hash-table") {
+		t.Error("pure profile has make-hash-table")
+	}
+}
+
+func TestDenyBeatsAllow(t *testing.T) {
+	vm := runtime.NewVM(
+		runtime.WithAllowedBuiltins("+", "-", "heap-dump"),
+		runtime.WithDeniedBuiltins("-"),
+		runtime.WithProfile(runtime.ProfileNoIO),
+	)
+
+	if got, want := vm.Builtins(), []string{"+"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("builtins %v, want %v", got, want)
+	}
+}
+
+func TestParseBuiltins(t *testing.T) {
+	names, err := runtime.ParseBuiltins("+,car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"+", "car"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("builtins %v, want %v", names, want)
+	}
+
+	for _, list := range []string{"+,cra", "", "+,"} {
+		if _, err := runtime.ParseBuiltins(list); err == nil {
+			t.Errorf("%q is accepted", list)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"time"
+)
+
+// number of pauses and thresholds kept in GC stats
+const gcStatsHistory = 100
+
+type GCStats struct {
+	Mode GCMode
+	// Collections is the number of finished collection cycles
+	Collections int
+	// Freed is the total number of freed objects
+	Freed int
+	// Live is the number of objects in the heap
+	Live          int
+	HeapSize      int
+	Fragmentation float64
+
+	// Pauses holds the longest pause of recent collections
+	Pauses     []time.Duration
+	MaxPause   time.Duration
+	TotalPause time.Duration
+
+	// Thresholds holds thresholds set after recent collections
+	Thresholds []int
+
+	// Types holds the number of live objects of each type
+	Types map[ObjectType]int
+}
+
+func (s *GCStats) record(pause time.Duration, threshold int) {
+	s.Collections++
+
+	s.TotalPause += pause
+	if pause > s.MaxPause {
+		s.MaxPause = pause
+	}
+
+	s.Pauses = append(s.Pauses, pause)
+	if len(s.Pauses) > gcStatsHistory {
+		s.Pauses = s.Pauses[1:]
+	}
+
+	s.Thresholds = append(s.Thresholds, threshold)
+	if len(s.Thresholds) > gcStatsHistory {
+		s.Thresholds = s.Thresholds[1:]
+	}
+}
+
+func (v *VM) GCStats() GCStats {
+	stats := v.gcStats
+
+	stats.Mode = v.gcMode
+	stats.Live = v.heap.Objects()
+	stats.HeapSize = v.heap.Capacity()
+	stats.Fragmentation = v.heap.Fragmentation()
+	stats.Pauses = append([]time.Duration(nil), v.gcStats.Pauses...)
+	stats.Thresholds = append([]int(nil), v.gcStats.Thresholds...)
+
+	stats.Types = make(map[ObjectType]int)
+	for _, o := range v.heap.memory {
+		if o != nil {
+			stats.Types[o.Type()]++
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,38 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+func TestGCStats(t *testing.T) {
+	for _, mode := range gcModes {
+		vm := runtime.NewVM(runtime.WithGCMode(mode), runtime.WithStdout(ioutil.Discard))
+		runVM(t, vm, "(define h (make-hash-table)) (car (1 2 3)) (gc)")
+
+		stats := vm.GCStats()
+		if stats.Mode != mode {
+			t.Errorf("%s: mode %s", mode, stats.Mode)
+		}
+		if stats.Collections == 0 || len(stats.Pauses) == 0 || len(stats.Thresholds) == 0 {
+			t.Errorf("%s: forced collection was not recorded: %+v", mode, stats)
+		}
+		if stats.Freed == 0 {
+			t.Errorf("%s: garbage list was not freed", mode)
+		}
+		if stats.Live != vm.Heap().Objects() {
+			t.Errorf("%s: %d live objects, heap holds %d", mode, stats.Live, vm.Heap().Objects())
+		}
+		if stats.Types[runtime.TypeHashTable] != 1 {
+			t.Errorf("%s: %d live hash tables, want 1", mode, stats.Types[runtime.TypeHashTable])
+		}
+
+		out := run(t, "(gc) (gc-stats)", runtime.WithGCMode(mode))
+		if !strings.Contains(out, "(mode "+mode.String()+" collections ") {
+			t.Errorf("%s: gc-stats printed %q", mode, out)
+		}
+	}
+}
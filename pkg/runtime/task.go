@@ -0,0 +1,295 @@
+package runtime
+
+import (
+	"context"
+	goruntime "runtime"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDeadlock aborts a task which would wait while every other task waits too
+var ErrDeadlock = errors.New("deadlock, all tasks are waiting")
+
+// yieldInterval is the number of steps after which running task lets other
+// tasks run
+const yieldInterval = 256
+
+// task is a goroutine evaluating on VM. Tasks share the heap and globals and
+// only the task holding the VM lock runs, so the heap is never accessed
+// concurrently. State of the running task is stored in VM, suspended tasks
+// keep their state in the task and it is a GC root.
+type task struct {
+	stack      *Stack
+	handles    []Object
+	env        *EnvironmentObject
+	evaluating bool
+	ctx        context.Context
+	steps      int
+	depth      int
+	// coroutine is set for tasks of coroutines
+	coroutine *CoroutineObject
+}
+
+func newTask() *task {
+	return &task{
+		stack:   NewStack(),
+		handles: make([]Object, 0),
+	}
+}
+
+func (t *task) save(v *VM) {
+	t.stack = v.stack
+	t.handles = v.handles
+	t.env = v.env
+	t.evaluating = v.evaluating
+	t.ctx = v.ctx
+	t.steps = v.steps
+	t.depth = v.depth
+
+	// state of suspended coroutine is referenced by the coroutine object
+	if t.coroutine != nil {
+		t.references(func(_ string, _ int, o Object) {
+			v.WriteBarrier(t.coroutine, o)
+		})
+	}
+}
+
+// resume makes t the running task, caller has to hold the VM lock
+func (v *VM) resume(t *task) {
+	v.stack = t.stack
+	v.handles = t.handles
+	v.env = t.env
+	v.evaluating = t.evaluating
+	v.ctx = t.ctx
+	v.steps = t.steps
+	v.depth = t.depth
+	v.current = t
+}
+
+// taskRoots calls f for every object held by suspended tasks
+func (v *VM) taskRoots(f func(name string, index int, o Object)) {
+	for n, t := range v.tasks {
+		if t == v.current {
+			continue
+		}
+
+		prefix := "task[" + strconv.Itoa(n) + "]."
+		t.references(func(name string, index int, o Object) {
+			f(prefix+name, index, o)
+		})
+	}
+}
+
+// references calls f for every object held by suspended task
+func (t *task) references(f func(name string, index int, o Object)) {
+	for i, o := range t.stack.stack {
+		f("stack", i, o)
+	}
+	for i, o := range t.handles {
+		f("handles", i, o)
+	}
+	if t.env != nil {
+		f("env", -1, t.env)
+	}
+}
+
+// spawn calls function with arguments in a new task and sends its result
+// into channel, which is closed when the task finishes. The task runs with
+// the context and remaining steps of the spawning task and only while the VM
+// evaluates.
+func (v *VM) spawn(function Object, args []Object, result *ChannelObject) {
+	t := newTask()
+	t.evaluating = v.evaluating
+	t.ctx = v.ctx
+	t.steps = v.steps
+	t.stack.Push(result)
+	t.stack.Push(function)
+	for _, arg := range args {
+		t.stack.Push(arg)
+	}
+
+	v.tasks = append(v.tasks, t)
+	go v.runTask(t, len(args))
+}
+
+func (v *VM) runTask(t *task, numArgs int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.resume(t)
+
+	result := v.stack.stack[0].(*ChannelObject)
+	function := v.stack.stack[1]
+
+	err := v.Try(func() {
+		result.put(v, function.EvaluateFunction(numArgs))
+	})
+	if err != nil {
+		v.PrintError(errors.Wrap(err, "task"))
+	}
+	result.closed = true
+
+	for i, other := range v.tasks {
+		if other == t {
+			v.tasks = append(v.tasks[:i], v.tasks[i+1:]...)
+			break
+		}
+	}
+	v.current = nil
+	v.notify()
+}
+
+// yield lets other tasks run
+func (v *VM) yield() {
+	if len(v.tasks) == 1 {
+		return
+	}
+
+	t := v.current
+	t.save(v)
+	v.lock.Unlock()
+	goruntime.Gosched()
+	v.lock.Lock()
+	v.resume(t)
+}
+
+// wait suspends the running task until another task calls notify or its
+// context is done. Caller has to check its condition again after wait returns.
+func (v *VM) wait() {
+	v.checkContext()
+
+	// tasks woken by notify are counted as running until they wait again
+	if v.waiting+1 >= len(v.tasks) {
+		Error(ErrDeadlock)
+	}
+	v.waiting++
+
+	if v.ctx != nil {
+		stop := context.AfterFunc(v.ctx, func() {
+			v.lock.Lock()
+			defer v.lock.Unlock()
+			v.notify()
+		})
+		defer stop()
+	}
+
+	t := v.current
+	t.save(v)
+	v.cond.Wait()
+	v.resume(t)
+
+	v.checkContext()
+}
+
+// checkContext aborts evaluation whose context is done
+func (v *VM) checkContext() {
+	if v.ctx == nil {
+		return
+	}
+	if err := v.ctx.Err(); err != nil {
+		Error(err)
+	}
+}
+
+// notify wakes every waiting task
+func (v *VM) notify() {
+	v.waiting = 0
+	v.cond.Broadcast()
+}
+
+func newScheduler(v *VM) {
+	v.cond = sync.NewCond(&v.lock)
+	v.current = newTask()
+	v.tasks = []*task{v.current}
+
+	// the goroutine using VM holds the lock until it waits or yields
+	v.lock.Lock()
+}
+
+// canSend reports whether value can be put into channel without waiting
+func (c *ChannelObject) canSend() bool {
+	return c.closed || len(c.buffer) < c.capacity || len(c.buffer) < c.receivers
+}
+
+// canReceive reports whether receive from channel does not have to wait
+func (c *ChannelObject) canReceive() bool {
+	return c.closed || len(c.buffer) > 0
+}
+
+func (c *ChannelObject) put(v *VM, value Object) {
+	if c.closed {
+		Error(errors.New("send on closed channel"))
+	}
+
+	c.buffer = append(c.buffer, value)
+	v.WriteBarrier(c, value)
+	v.notify()
+}
+
+// take returns the first value in channel or nil when it is closed and empty
+func (c *ChannelObject) take(v *VM) Object {
+	if len(c.buffer) == 0 {
+		return NewNilObject().Allocate(v)
+	}
+
+	value := c.buffer[0]
+	c.buffer[0] = nil
+	c.buffer = c.buffer[1:]
+	v.notify()
+	return value
+}
+
+// send puts value into channel, waiting until there is space or a receiver
+func (v *VM) send(c *ChannelObject, value Object) {
+	scope := v.OpenScope()
+	defer scope.Close()
+	scope.Root(c)
+	scope.Root(value)
+
+	for !c.canSend() {
+		v.wait()
+	}
+	c.put(v, value)
+}
+
+// receive takes value from the first channel which has one and returns its
+// index. When block is false and no channel has a value it returns -1.
+func (v *VM) receive(channels []*ChannelObject, block bool) (int, Object) {
+	if i, ok := ready(channels); ok || !block {
+		if !ok {
+			return -1, nil
+		}
+		return i, channels[i].take(v)
+	}
+
+	scope := v.OpenScope()
+	defer scope.Close()
+	for _, c := range channels {
+		scope.Root(c)
+		c.receivers++
+	}
+	defer func() {
+		for _, c := range channels {
+			c.receivers--
+		}
+	}()
+
+	// senders to channels without buffer wait for receivers
+	v.notify()
+	for {
+		if i, ok := ready(channels); ok {
+			return i, channels[i].take(v)
+		}
+		v.wait()
+	}
+}
+
+func ready(channels []*ChannelObject) (int, bool) {
+	for i, c := range channels {
+		if c.canReceive() {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package runtime_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"lisp-interpreter/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+const loop = `(define loop (lambda (n) (loop (+ n 1))))`
+
+func newLoopVM(t *testing.T, limits runtime.Limits) *runtime.VM {
+	vm := runtime.NewVM(
+		runtime.WithEvalMode(runtime.ModeBytecode),
+		runtime.WithStderr(ioutil.Discard),
+		runtime.WithLimits(limits),
+	)
+	if err := evalContext(context.Background(), t, vm, loop); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestTaskInheritsLimits(t *testing.T) {
+	vm := newLoopVM(t, runtime.Limits{})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := evalContext(ctx, t, vm, "(receive (spawn loop 0))"); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("context: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the task fails and closes its channel
+	out := run(t, loop+"(receive (spawn loop 0))",
+		runtime.WithEvalMode(runtime.ModeBytecode),
+		runtime.WithLimits(runtime.Limits{MaxSteps: 10000}))
+	if !strings.Contains(out, runtime.ErrStepLimit.Error()) {
+		t.Errorf("steps: got %q, want %v", out, runtime.ErrStepLimit)
+	}
+}
+
+func TestWaitAbortsWhenContextIsDone(t *testing.T) {
+	vm := newLoopVM(t, runtime.Limits{})
+	if err := evalContext(context.Background(), t, vm, "(define ch (spawn loop 0))"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := evalContext(ctx, t, vm, "(receive ch)"); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// channels passed to select are referenced only by its arguments
+func TestSelectInStressMode(t *testing.T) {
+	src := "(select (spawn (lambda () 5)) (lambda (x) (+ x 1)))\n(select (make-channel) (lambda (x) x) (lambda () 7))\n"
+	for _, eval := range []runtime.EvalMode{runtime.ModeTree, runtime.ModeBytecode} {
+		var out strings.Builder
+		vm := runtime.NewVM(
+			runtime.WithEvalMode(eval),
+			runtime.WithGCStress(true),
+			runtime.WithStdout(&out),
+			runtime.WithStderr(&out),
+		)
+		runVM(t, vm, src)
+
+		if violations := vm.Violations(); len(violations) != 0 {
+			t.Errorf("%s: violations %v", eval, violations)
+		}
+		if out.String() != "6\n7\n" {
+			t.Errorf("%s: got %q, want %q", eval, out.String(), "6\n7\n")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"fmt"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// max number of problems reported by one heap verification
+const verifyMaxProblems = 10
+
+// WithGCStress collects the whole heap on every allocation, remembers where
+// every object was allocated and reports any use of a freed object. The heap is
+// verified after every collection.
+func WithGCStress(stress bool) Option {
+	return func(v *VM) {
+		v.gcStress = stress
+	}
+}
+
+// allocationSite returns the first caller outside of allocation functions
+func allocationSite() string {
+	pcs := make([]uintptr, 16)
+	n := goruntime.Callers(3, pcs)
+	frames := goruntime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasSuffix(frame.Function, ".Allocate") && !strings.HasSuffix(frame.Function, ".AllocateObject") {
+			return fmt.Sprintf("%s:%d %s", shortPath(frame.File), frame.Line, frame.Function)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func shortPath(path string) string {
+	if i := strings.LastIndex(path, "/pkg/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func (v *VM) describe(o Object) string {
+	if site, found := v.allocationSites[o]; found {
+		return fmt.Sprintf("%s allocated at %s", o.Type(), site)
+	}
+	if site, found := v.poisoned[o]; found {
+		return fmt.Sprintf("freed %s allocated at %s", o.Type(), site)
+	}
+	return fmt.Sprintf("unallocated %s", o.Type())
+}
+
+// checkLive reports use of an object which was freed, it only works in stress
+// mode
+func (v *VM) checkLive(o Object) {
+	if !v.gcStress || o == nil {
+		return
+	}
+
+	if _, found := v.poisoned[o]; found {
+		v.reportViolation(errors.Errorf("use of %s", v.describe(o)))
+	}
+}
+
+func (v *VM) reportViolation(err error) {
+	v.violations = append(v.violations, err)
+	v.PrintError(err)
+}
+
+// Violations returns double frees and problems found in stress mode
+func (v *VM) Violations() []error {
+	return v.violations
+}
+
+// VerifyHeap checks heap bookkeeping and that every object reachable from
+// roots is stored in the heap
+func (v *VM) VerifyHeap() error {
+	problems := make([]string, 0)
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	objects := 0
+	for blockIndex, o := range v.heap.memory {
+		if o == nil {
+			continue
+		}
+
+		objects++
+		if indexed, found := v.heap.index[o]; !found || indexed != blockIndex {
+			report("slot %d holds %s which is not indexed", blockIndex, o.Type())
+		}
+	}
+
+	if objects != v.heap.objects || objects != len(v.heap.index) {
+		report("heap holds %d objects but counts %d and indexes %d", objects, v.heap.objects, len(v.heap.index))
+	}
+
+	for _, blockIndex := range v.heap.free {
+		if v.heap.memory[blockIndex] != nil {
+			report("free slot %d holds %s", blockIndex, v.heap.memory[blockIndex].Type())
+		}
+	}
+
+	if objects+len(v.heap.free) != v.heap.Capacity() {
+		report("heap has %d objects and %d free slots but capacity %d", objects, len(v.heap.free), v.heap.Capacity())
+	}
+
+	visited := make(map[Object]bool)
+	gray := make([]Object, 0)
+	visit := func(o Object, from string) {
+		if o == nil || visited[o] {
+			return
+		}
+		visited[o] = true
+
+		if v.isCollected(o) {
+			report("%s references %s", from, v.describe(o))
+			return
+		}
+		gray = append(gray, o)
+	}
+
+	v.roots(func(o Object) {
+		visit(o, "root")
+	})
+	for len(gray) > 0 {
+		l := len(gray)
+		o := gray[l-1]
+		gray = gray[:l-1]
+
+		for _, ref := range o.References() {
+			visit(ref, v.describe(o))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(problems) > verifyMaxProblems {
+		problems = append(problems[:verifyMaxProblems], fmt.Sprintf("%d more", len(problems)-verifyMaxProblems))
+	}
+	return errors.Errorf("heap verification failed: %s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,84 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+func TestStressReportsUseOfFreedObject(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithGCStress(true), runtime.WithStderr(ioutil.Discard))
+
+	// symbol is not rooted, so collection frees it
+	symbol := runtime.NewSymbolObject("freed").Allocate(vm)
+	vm.GC()
+	vm.Try(func() { symbol.Evaluate() })
+
+	violations := vm.Violations()
+	if len(violations) == 0 || !strings.Contains(violations[0].Error(), "use of freed symbol") {
+		t.Errorf("use of freed symbol is not reported, violations %v", violations)
+	}
+}
+
+func TestStressForgetsFreedObjectWhoseBlockIsReused(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithGCStress(true), runtime.WithStderr(ioutil.Discard))
+
+	symbol := runtime.NewSymbolObject("freed").Allocate(vm)
+	vm.GC()
+	// allocation reuses the block of the freed symbol
+	runtime.NewSymbolObject("next").Allocate(vm)
+	vm.Try(func() { symbol.Evaluate() })
+
+	if violations := vm.Violations(); len(violations) != 0 {
+		t.Errorf("freed object is still tracked after its block is reused, violations %v", violations)
+	}
+}
+
+func TestDoubleFreeIsReported(t *testing.T) {
+	vm := runtime.NewVM(runtime.WithStderr(ioutil.Discard))
+
+	symbol := runtime.NewSymbolObject("freed").Allocate(vm)
+	blockIndex, found := vm.Heap().BlockIndex(symbol)
+	if !found {
+		t.Fatal("allocated symbol is not in the heap")
+	}
+	vm.FreeObject(blockIndex)
+	vm.FreeObject(blockIndex)
+
+	violations := vm.Violations()
+	if len(violations) != 1 || !strings.Contains(violations[0].Error(), "double free") {
+		t.Errorf("double free is not reported, violations %v", violations)
+	}
+}
+
+// readExample reads example program from the repository root
+func readExample(t *testing.T, name string) string {
+	t.Helper()
+
+	src, err := ioutil.ReadFile("../../" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(src)
+}
+
+func TestStress(t *testing.T) {
+	for _, name := range []string{"testInput", "testGc"} {
+		src := readExample(t, name)
+		for _, mode := range gcModes {
+			vm := runtime.NewVM(
+				runtime.WithGCMode(mode),
+				runtime.WithGCStress(true),
+				runtime.WithStdout(ioutil.Discard),
+				runtime.WithStderr(ioutil.Discard),
+			)
+			runVM(t, vm, src)
+
+			for _, err := range vm.Violations() {
+				t.Errorf("%s %s: %v", name, mode, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package runtime
+
+// finalizer is a function called after its object became unreachable. Lisp
+// finalizers keep their function object, so it is kept alive as a root.
+type finalizer struct {
+	object   Object
+	f        func(Object)
+	function Object
+}
+
+// SetFinalizer registers f to be called after o becomes unreachable. The object
+// survives the collection which found it unreachable and f receives it after
+// the collection finishes. It is freed by a later collection unless f stores
+// it somewhere.
+func (v *VM) SetFinalizer(o Object, f func(Object)) {
+	v.finalizers = append(v.finalizers, finalizer{object: o, f: f})
+}
+
+func (v *VM) setLispFinalizer(o Object, function Object) {
+	v.finalizers = append(v.finalizers, finalizer{
+		object: o,
+		f: func(o Object) {
+			v.Stack().Push(o)
+			function.EvaluateFunction(1)
+		},
+		function: function,
+	})
+}
+
+// queueFinalizers is called by collectors after marking. Objects with
+// finalizers which are not live are queued and kept alive by calling keep.
+func (v *VM) queueFinalizers(isLive func(Object) bool, keep func(Object)) {
+	registered := v.finalizers[:0]
+
+	for _, f := range v.finalizers {
+		if _, found := v.heap.BlockIndex(f.object); !found {
+			continue
+		}
+
+		if isLive(f.object) {
+			registered = append(registered, f)
+		} else {
+			v.finalizing = append(v.finalizing, f)
+			keep(f.object)
+		}
+	}
+
+	v.finalizers = registered
+}
+
+func (v *VM) runFinalizers() {
+	if v.runningFinalizers {
+		return
+	}
+
+	v.runningFinalizers = true
+	for len(v.finalizing) > 0 {
+		f := v.finalizing[0]
+		v.finalizing = v.finalizing[1:]
+
+		if err := v.Try(func() { f.f(f.object) }); err != nil {
+			v.PrintError(err)
+		}
+	}
+	v.runningFinalizers = false
+}
+
+// finalizerRoots calls f for objects which have to stay alive because of
+// finalizers
+func (v *VM) finalizerRoots(f func(name string, index int, o Object)) {
+	for i, fin := range v.finalizers {
+		if fin.function != nil {
+			f("finalizers", i, fin.function)
+		}
+	}
+
+	for i, fin := range v.finalizing {
+		f("finalizing", i, fin.object)
+		if fin.function != nil {
+			f("finalizing", i, fin.function)
+		}
+	}
+}
+
+func (v *VM) isCollected(o Object) bool {
+	if isImmortal(o) {
+		return false
+	}
+
+	_, found := v.heap.BlockIndex(o)
+	return !found
+}
+
+// clearWeakReferences removes weak references to objects which were freed
+func (v *VM) clearWeakReferences() {
+	for box := range v.weakBoxes {
+		if v.isCollected(box) {
+			delete(v.weakBoxes, box)
+		} else if box.value != nil && v.isCollected(box.value) {
+			box.value = nil
+		}
+	}
+
+	for table := range v.weakTables {
+		if v.isCollected(table) {
+			delete(v.weakTables, table)
+			continue
+		}
+
+		for key, entry := range table.entries {
+			if v.isCollected(entry.key) {
+				delete(table.entries, key)
+			}
+		}
+	}
+}
+
+// markFrom marks o and everything reachable from it
+func (v *VM) markFrom(o Object) {
+	if o.IsMarked() {
+		return
+	}
+
+	o.Mark()
+	trace([]Object{o}, traceAll)
+}
+
+func isMarked(o Object) bool {
+	return o.IsMarked()
+}
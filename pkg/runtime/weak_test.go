@@ -0,0 +1,42 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"lisp-interpreter/pkg/runtime"
+)
+
+// value passed to make-weak-box survives allocation of the box
+func TestMakeWeakBoxRootsValue(t *testing.T) {
+	for _, mode := range gcModes {
+		vm := runtime.NewVM(
+			runtime.WithGCMode(mode),
+			runtime.WithGCStress(true),
+			runtime.WithStdout(ioutil.Discard),
+			runtime.WithStderr(ioutil.Discard),
+		)
+		makeWeakBox, _ := vm.Lookup("make-weak-box")
+		weakBoxValue, _ := vm.Lookup("weak-box-value")
+
+		table := runtime.NewHashTableObject(false).Allocate(vm)
+		box, err := vm.Apply(makeWeakBox, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, found := vm.Heap().BlockIndex(table); !found {
+			t.Errorf("%s: value was freed while allocating weak box", mode)
+		}
+
+		value, err := vm.Apply(weakBoxValue, box)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != table {
+			t.Errorf("%s: weak box holds %s", mode, value)
+		}
+		for _, err := range vm.Violations() {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+}